@@ -0,0 +1,61 @@
+package coreweb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// weighted is a value listed in an Accept header with its quality.
+type weighted struct {
+	value string
+	q     float64
+}
+
+// parseWeighted splits an Accept or Accept-Language header into values with
+// their q parameter, in the order listed. Values without one have quality 1.
+func parseWeighted(header string) []weighted {
+	list := []weighted{}
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		value := strings.TrimSpace(fields[0])
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		list = append(list, weighted{value, q})
+	}
+	return list
+}
+
+// mediaQuality returns the quality an Accept header gives a media type, from
+// its most specific matching range, and the position of that range. The
+// position is -1 if no range matches.
+func mediaQuality(ranges []weighted, mediaType string) (float64, int) {
+	kind := strings.SplitN(mediaType, "/", 2)[0]
+	best, q, position := 0, 0.0, -1
+
+	for i, r := range ranges {
+		specificity := 0
+		switch strings.ToLower(r.value) {
+		case mediaType:
+			specificity = 3
+		case kind + "/*":
+			specificity = 2
+		case "*/*":
+			specificity = 1
+		}
+		if specificity > best {
+			best, q, position = specificity, r.q, i
+		}
+	}
+	return q, position
+}
@@ -0,0 +1,93 @@
+package coreweb
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/token"
+)
+
+const bearer = "Bearer "
+
+// allowAdmin indicates whether a request may use administrative endpoints.
+// The client address must be loopback or within Config.AdminAllow and the
+// request must bear an authorization token with Config.AdminPermission.
+func allowAdmin(c Config, r *http.Request) bool {
+	return allowAddress(c.AdminAllow, r) && hasPermission(r, c.AdminPermission)
+}
+
+// allowAddress indicates whether the request came from a loopback address or
+// one matching an IP or CIDR range in the list.
+func allowAddress(list []string, r *http.Request) bool {
+	ip := clientIP(r)
+	return ip != nil && (ip.IsLoopback() || listed(list, ip))
+}
+
+// clientIP parses the remote address of a request.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// listed indicates whether the IP matches an address or CIDR range in the
+// list.
+func listed(list []string, ip net.IP) bool {
+	for _, allowed := range list {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorization decodes the valid authorization token, base64 URL encoded,
+// that a request bears.
+func authorization(r *http.Request) (*token.AuthToken, bool) {
+	auth := r.Header.Get(header.Authorization)
+	if !strings.HasPrefix(auth, bearer) {
+		return nil, false
+	}
+	raw, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(auth, bearer))
+	if err != nil {
+		return nil, false
+	}
+	t, err := token.DecodeAuthorization(raw, true)
+	if err != nil {
+		return nil, false
+	}
+	return t, true
+}
+
+// Tenant returns the tenant ID of the request's authorization token or zero
+// if it has none.
+func Tenant(r *http.Request) int64 {
+	if t, ok := authorization(r); ok {
+		return t.TenantID
+	}
+	return 0
+}
+
+// hasPermission indicates whether the request bears a valid authorization
+// token that includes the permission.
+func hasPermission(r *http.Request, permission uint16) bool {
+	t, ok := authorization(r)
+	if !ok {
+		return false
+	}
+	for _, p := range t.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -111,6 +111,12 @@ func Providers() []*AuthProvider {
 	return enabled
 }
 
+// AllProviders returns all authentication providers whether or not they're
+// enabled.
+func AllProviders() []*AuthProvider {
+	return providers
+}
+
 // GetProviderForKey returns the Provider instance with the given Key.
 func GetProviderForKey(key string) *AuthProvider {
 	for _, p := range providers {
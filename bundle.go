@@ -0,0 +1,243 @@
+package coreweb
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+const (
+	// defaultBundleHistory is how many uploaded bundles are kept for rollback
+	// when Config.BundleHistory isn't set.
+	defaultBundleHistory = 3
+	// maxBundleSize limits the bytes accepted for a bundle upload.
+	maxBundleSize = 256 << 20
+	rollbackPath  = webSlash + "rollback"
+)
+
+var errBadSignature = errors.New("Bundle signature is not valid for any configured key")
+
+// bundle is an uploaded set of static files ready to be swapped in.
+type bundle struct {
+	version string
+	m       *file.Map
+}
+
+// bundles are those uploaded and still available for rollback, the active
+// one last.
+var bundles = struct {
+	sync.Mutex
+	history []*bundle
+}{}
+
+// HandleBundles responds to administrative requests to upload a signed static
+// bundle, list the bundles kept for rollback or roll back to one of them. It
+// must be mounted after Handle has created the static file cache.
+//
+// A bundle is uploaded with a multipart POST having a "bundle" zip archive,
+// a base64 encoded detached Ed25519 "signature" of the archive and an
+// optional "version" that otherwise defaults to part of the archive hash.
+// A POST to a path ending in /rollback activates the previous bundle or the
+// one named by a "version" form value. Rolling back past the oldest bundle
+// reloads the configured static files.
+func HandleBundles(c Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowAdmin(c, r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if static == nil {
+			http.Error(w, "Static files are not loaded", http.StatusServiceUnavailable)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet:
+			writeVersions(w)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, rollbackPath):
+			if err := rollback(r.FormValue("version")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeVersions(w)
+		case r.Method == http.MethodPost:
+			if err := upload(c, w, r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeVersions(w)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// upload verifies and activates a bundle posted in a multipart form.
+func upload(c Config, w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBundleSize)
+
+	f, _, err := r.FormFile("bundle")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.FormValue("signature"))
+	if err != nil {
+		return err
+	}
+	if err = verifyBundle(c.BundleKeys, data, signature); err != nil {
+		return err
+	}
+
+	version := r.FormValue("version")
+	if version == "" {
+		hash := sha256.Sum256(data)
+		version = hex.EncodeToString(hash[:6])
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	m, err := file.InFS(zr)
+	if err != nil {
+		return err
+	}
+	m.Version = version
+
+	if m, err = build(static.config, m, static.modulePaths); err != nil {
+		return err
+	}
+
+	limit := c.BundleHistory
+	if limit <= 0 {
+		limit = defaultBundleHistory
+	}
+	bundles.Lock()
+	defer bundles.Unlock()
+
+	bundles.history = append(bundles.history, &bundle{version: version, m: m})
+	if len(bundles.history) > limit {
+		bundles.history = bundles.history[len(bundles.history)-limit:]
+	}
+	static.cache.Replace(m)
+	log.Printf("Activated static bundle %s", version)
+
+	return nil
+}
+
+// verifyBundle returns an error unless the signature of the data is valid for
+// at least one of the base64 encoded Ed25519 public keys.
+func verifyBundle(keys []string, data, signature []byte) error {
+	for _, k := range keys {
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			log.Printf("Invalid bundle key %s", k)
+			continue
+		}
+		if ed25519.Verify(ed25519.PublicKey(key), data, signature) {
+			return nil
+		}
+	}
+	return errBadSignature
+}
+
+// rollback activates the named bundle or, if version is empty, the one before
+// the active bundle. Rolling back past the oldest bundle reloads the
+// configured static files.
+func rollback(version string) error {
+	bundles.Lock()
+	defer bundles.Unlock()
+
+	keep := len(bundles.history) - 1
+
+	if version != "" {
+		keep = -1
+		for i, b := range bundles.history {
+			if b.version == version {
+				keep = i + 1
+			}
+		}
+		if keep < 0 {
+			return errors.New("No bundle with version " + version)
+		}
+	}
+	if keep <= 0 {
+		m, err := load(static.config, static.modulePaths)
+		if err != nil {
+			return err
+		}
+		bundles.history = nil
+		static.cache.Replace(m)
+		log.Print("Rolled back to configured static files")
+		return nil
+	}
+	bundles.history = bundles.history[:keep]
+	active := bundles.history[keep-1]
+	static.cache.Replace(active.m)
+	log.Printf("Rolled back to static bundle %s", active.version)
+
+	return nil
+}
+
+// writeVersions responds with the active and retained bundle versions, most
+// recent first.
+func writeVersions(w http.ResponseWriter) {
+	bundles.Lock()
+	versions := make([]string, len(bundles.history))
+	for i, b := range bundles.history {
+		versions[len(versions)-1-i] = b.version
+	}
+	bundles.Unlock()
+
+	data, err := json.Marshal(map[string]interface{}{
+		"active":   static.cache.Map().Version,
+		"versions": versions,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(content.Type, mime.JSON)
+	w.Write(data)
+}
+
+// reloadArchive swaps in static files from the configured zip archive after
+// it changes, unless an uploaded bundle is active. Rolling back past the
+// oldest bundle loads the changed archive.
+func reloadArchive(c Config, modulePaths []string, cache *file.Cache) {
+	m, err := load(c, modulePaths)
+	if err != nil {
+		log.Printf("Unable to reload %s: %v", c.FromZip, err)
+		return
+	}
+	bundles.Lock()
+	defer bundles.Unlock()
+
+	if n := len(bundles.history); n > 0 {
+		m.Close()
+		log.Printf("Not reloading %s while static bundle %s is active", c.FromZip, bundles.history[n-1].version)
+		return
+	}
+	cache.Replace(m)
+	log.Printf("Reloaded static files from %s", c.FromZip)
+}
@@ -0,0 +1,101 @@
+package coreweb
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+func TestVerifyBundle(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	data := []byte("bundle")
+	signature := ed25519.Sign(private, data)
+	keys := []string{
+		base64.StdEncoding.EncodeToString(other),
+		base64.StdEncoding.EncodeToString(public),
+	}
+
+	assert.NoError(t, verifyBundle(keys, data, signature))
+	assert.Equal(t, errBadSignature, verifyBundle(keys[:1], data, signature))
+	assert.Equal(t, errBadSignature, verifyBundle(keys, []byte("altered"), signature))
+}
+
+func TestAllowAddress(t *testing.T) {
+	r := httptest.NewRequest("GET", "/admin", nil)
+	allow := []string{"10.0.0.0/8", "192.168.1.5"}
+
+	r.RemoteAddr = "127.0.0.1:4000"
+	assert.True(t, allowAddress(nil, r))
+
+	r.RemoteAddr = "10.2.3.4:4000"
+	assert.True(t, allowAddress(allow, r))
+	assert.False(t, allowAddress(nil, r))
+
+	r.RemoteAddr = "192.168.1.5:4000"
+	assert.True(t, allowAddress(allow, r))
+
+	r.RemoteAddr = "192.168.1.6:4000"
+	assert.False(t, allowAddress(allow, r))
+}
+
+// TestReloadArchive ensures a changed archive doesn't replace an uploaded
+// bundle.
+func TestReloadArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "static.zip")
+	f, err := os.Create(archive)
+	assert.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, data := range map[string]string{
+		"html/template.html": "<html><head></head></html>",
+		"js/app.js":          "var a = 1;",
+	} {
+		entry, err := w.Create(name)
+		assert.NoError(t, err)
+		entry.Write([]byte(data))
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+
+	uploaded, err := file.InFS(fstest.MapFS{"uploaded.js": {Data: []byte("var b = 2;")}})
+	assert.NoError(t, err)
+	cache := file.NewCache(uploaded)
+	c := Config{FromZip: archive}
+
+	bundles.Lock()
+	history := bundles.history
+	bundles.history = []*bundle{{version: "1", m: uploaded}}
+	bundles.Unlock()
+	defer func() {
+		bundles.Lock()
+		bundles.history = history
+		bundles.Unlock()
+	}()
+
+	reloadArchive(c, nil, cache)
+	assert.Equal(t, uploaded, cache.Map())
+
+	bundles.Lock()
+	bundles.history = nil
+	bundles.Unlock()
+
+	reloadArchive(c, nil, cache)
+	assert.Contains(t, cache.Map().Files, "js/app.js")
+}
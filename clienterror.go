@@ -0,0 +1,286 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toba/coreweb/file"
+)
+
+const (
+	// maxErrorReportSize is the largest client error report accepted.
+	maxErrorReportSize = 16 << 10
+	// maxErrorGroups is how many distinct errors are tracked. Others are
+	// logged but not grouped.
+	maxErrorGroups = 1000
+	// maxStackFrames is how many stack frames are resolved and kept.
+	maxStackFrames = 50
+	// fingerprintFrames is how many of the top frames identify an error
+	// along with its message.
+	fingerprintFrames = 5
+	// maxErrorModules is how many modules are listed for an error group.
+	maxErrorModules = 20
+)
+
+type (
+	// ClientError is an uncaught error reported by a browser.
+	ClientError struct {
+		Message string `json:"message"`
+		// Stack is the error stack as formatted by the browser.
+		Stack string `json:"stack"`
+		// URL of the page where the error occurred.
+		URL       string `json:"url"`
+		UserAgent string `json:"userAgent,omitempty"`
+		// Module is the module path of the page, inferred from URL if not
+		// given.
+		Module string `json:"module,omitempty"`
+		// Tenant is taken from the authorization of the reporting request,
+		// not from the report.
+		Tenant int64 `json:"-"`
+		// Address is the client's network address, with or without a port,
+		// used to limit reports.
+		Address string `json:"-"`
+	}
+
+	// Frame is a stack frame resolved to its original source, if a source
+	// map was found.
+	Frame struct {
+		Function string `json:"function,omitempty"`
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	}
+
+	// ErrorGroup is reports of the same error, identified by a fingerprint
+	// of its message and top resolved stack frames.
+	ErrorGroup struct {
+		Fingerprint string    `json:"fingerprint"`
+		Message     string    `json:"message"`
+		Frames      []Frame   `json:"frames"`
+		Modules     []string  `json:"modules"`
+		Count       int64     `json:"count"`
+		First       time.Time `json:"first"`
+		Last        time.Time `json:"last"`
+	}
+)
+
+var (
+	// stackLine matches Chrome ("at fn (url:1:2)") and Firefox or Safari
+	// ("fn@url:1:2") stack frames.
+	stackLine = regexp.MustCompile(`^\s*(?:at\s+)?(?:(.*?)\s*\(|(.*?)@)?(\S+?):(\d+):(\d+)\)?\s*$`)
+
+	clientErrors = struct {
+		sync.Mutex
+		once    sync.Once
+		limiter *limiter
+		groups  map[string]*ErrorGroup
+	}{groups: make(map[string]*ErrorGroup)}
+)
+
+// ClientErrors lists the groups of errors reported by browsers.
+func ClientErrors() []ErrorGroup {
+	clientErrors.Lock()
+	defer clientErrors.Unlock()
+
+	list := make([]ErrorGroup, 0, len(clientErrors.groups))
+	for _, g := range clientErrors.groups {
+		list = append(list, *g)
+	}
+	return list
+}
+
+// ReportClientError resolves the stack of an error reported by a browser
+// through source maps in the static files, groups it with others having the
+// same fingerprint and logs it. False is returned if the client has sent
+// more than Config.ReportLimit reports in the last minute.
+func ReportClientError(c Config, e ClientError) bool {
+	clientErrors.once.Do(func() {
+		limit := c.ReportLimit
+		if limit <= 0 {
+			limit = defaultReportLimit
+		}
+		clientErrors.limiter = newLimiter(limit, time.Minute)
+	})
+	if host, _, err := net.SplitHostPort(e.Address); err == nil {
+		e.Address = host
+	}
+	if !clientErrors.limiter.allow(e.Address) {
+		return false
+	}
+	var m *file.Map
+	if static != nil {
+		m = static.cache.Map()
+	}
+	if e.Module == "" {
+		e.Module = pageModule(c, e.URL)
+	}
+	e.Module = truncate(e.Module)
+	frames := resolveStack(m, e.Stack)
+	fingerprint := errorFingerprint(e.Message, frames)
+	now := time.Now()
+
+	clientErrors.Lock()
+	g, seen := clientErrors.groups[fingerprint]
+	if !seen && len(clientErrors.groups) < maxErrorGroups {
+		g = &ErrorGroup{
+			Fingerprint: fingerprint,
+			Message:     truncate(e.Message),
+			Frames:      frames,
+			First:       now,
+		}
+		clientErrors.groups[fingerprint] = g
+	}
+	count := int64(1)
+	if g != nil {
+		g.Count++
+		g.Last = now
+		if len(g.Modules) < maxErrorModules && !containsString(g.Modules, e.Module) {
+			g.Modules = append(g.Modules, e.Module)
+		}
+		count = g.Count
+	}
+	clientErrors.Unlock()
+
+	log.Printf("Client error %s (%d) in module %q for tenant %d: %q",
+		fingerprint, count, e.Module, e.Tenant, truncate(e.Message))
+	if !seen {
+		for _, f := range frames {
+			log.Printf("   at %s (%s:%d:%d)", f.Function, f.File, f.Line, f.Column)
+		}
+	}
+	return true
+}
+
+// HandleClientErrors accepts JSON error reports from browsers that can't use
+// the socket connection, such as before it opens.
+func HandleClientErrors(c Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxErrorReportSize+1))
+		if err != nil || len(data) > maxErrorReportSize {
+			http.Error(w, "Report is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		e := ClientError{}
+		if err := json.Unmarshal(data, &e); err != nil || e.Message == "" {
+			http.Error(w, "Report must be JSON with a message", http.StatusBadRequest)
+			return
+		}
+		e.Address = clientAddress(r)
+		e.Tenant = Tenant(r)
+		if e.UserAgent == "" {
+			e.UserAgent = r.UserAgent()
+		}
+		if !ReportClientError(c, e) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// resolveStack parses stack frames and maps those in static scripts to
+// their original source.
+func resolveStack(m *file.Map, stack string) []Frame {
+	frames := []Frame{}
+
+	for _, text := range strings.Split(stack, "\n") {
+		if len(frames) == maxStackFrames {
+			break
+		}
+		match := stackLine.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		f := Frame{Function: strings.TrimSpace(match[1] + match[2]), File: match[3]}
+		f.Line, _ = strconv.Atoi(match[4])
+		f.Column, _ = strconv.Atoi(match[5])
+
+		if m != nil {
+			if sm := scriptSourceMap(m, scriptKey(f.File)); sm != nil {
+				if source, line, column, name, ok := sm.find(f.Line, f.Column); ok {
+					f.File, f.Line, f.Column = source, line, column
+					if name != "" {
+						f.Function = name
+					}
+				}
+			}
+		}
+		f.Function = truncate(f.Function)
+		f.File = truncate(f.File)
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// errorFingerprint identifies an error by its message and the functions
+// and files of its top frames. Line numbers are left out so errors stay
+// grouped when unrelated code moves.
+func errorFingerprint(message string, frames []Frame) string {
+	var b strings.Builder
+	b.WriteString(message)
+
+	for i, f := range frames {
+		if i == fingerprintFrames {
+			break
+		}
+		fmt.Fprintf(&b, "\n%s %s", f.Function, f.File)
+	}
+	return revision([]byte(b.String()))
+}
+
+// scriptKey converts a script URL from a stack frame to a static file key.
+func scriptKey(script string) string {
+	if u, err := url.Parse(script); err == nil {
+		script = u.Path
+	}
+	return strings.TrimPrefix(script, webSlash)
+}
+
+// pageModule finds the module path serving a page URL as Handle does,
+// without any locale prefix and matching nested module paths first.
+func pageModule(c Config, page string) string {
+	u, err := url.Parse(page)
+	if err != nil {
+		return ""
+	}
+	key := strings.Trim(u.Path, webSlash)
+	if len(c.Locales) > 0 {
+		_, key, _ = localePrefix(c, key)
+	}
+	var modules routes
+	if static != nil {
+		modules = newRoutes(static.modulePaths)
+	}
+	if module, ok := modules.match(key); ok {
+		return module
+	}
+	if c.FallbackModule != "" && path.Ext(key) == "" {
+		return c.FallbackModule
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
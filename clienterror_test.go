@@ -0,0 +1,105 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+func TestResolveStack(t *testing.T) {
+	m, err := file.InFS(mappedScript)
+	assert.NoError(t, err)
+
+	chrome := "Error: x\n" +
+		"    at a (https://example.com/js/app.js:1:20)\n" +
+		"    at https://example.com/js/vendor.js?v=2:4:8"
+	assert.Equal(t, []Frame{
+		{Function: "boom", File: "src/app.ts", Line: 3, Column: 5},
+		{File: "https://example.com/js/vendor.js?v=2", Line: 4, Column: 8},
+	}, resolveStack(m, chrome))
+
+	firefox := "a@https://example.com/js/app.js:1:20\n@https://example.com/js/vendor.js:2:3\n"
+	assert.Equal(t, []Frame{
+		{Function: "boom", File: "src/app.ts", Line: 3, Column: 5},
+		{File: "https://example.com/js/vendor.js", Line: 2, Column: 3},
+	}, resolveStack(m, firefox))
+}
+
+func TestErrorFingerprint(t *testing.T) {
+	frames := []Frame{{Function: "boom", File: "src/app.ts", Line: 3, Column: 5}}
+	moved := []Frame{{Function: "boom", File: "src/app.ts", Line: 30, Column: 5}}
+
+	assert.Equal(t, errorFingerprint("x", frames), errorFingerprint("x", moved))
+	assert.NotEqual(t, errorFingerprint("x", frames), errorFingerprint("y", frames))
+}
+
+func TestPageModule(t *testing.T) {
+	previous := static
+	defer func() { static = previous }()
+	static = &site{modulePaths: []string{"app", "app/admin", "shop"}}
+
+	c := Config{Locales: []string{"en", "de"}}
+	for page, expect := range map[string]string{
+		"https://example.com/app":             "app",
+		"https://example.com/de/app/x":        "app",
+		"https://example.com/app/admin/users": "app/admin",
+		"https://example.com/de/shop/":        "shop",
+		"https://example.com/de":              "",
+		"https://example.com/js/app.js":       "",
+		"https://example.com/other/page":      "",
+	} {
+		assert.Equal(t, expect, pageModule(c, page), page)
+	}
+	c.FallbackModule = "app"
+	assert.Equal(t, "app", pageModule(c, "https://example.com/other/page"))
+	assert.Equal(t, "", pageModule(c, "https://example.com/img/logo.png"))
+}
+
+func TestHandleClientErrors(t *testing.T) {
+	previous := static
+	defer func() { static = previous }()
+	m, err := file.InFS(mappedScript)
+	assert.NoError(t, err)
+	static = &site{modulePaths: []string{"shop"}, cache: file.NewCache(m)}
+
+	// groups and limits start empty so the test may run repeatedly
+	clientErrors.Lock()
+	groups, limits := clientErrors.groups, clientErrors.limiter
+	clientErrors.groups = make(map[string]*ErrorGroup)
+	clientErrors.limiter = newLimiter(defaultReportLimit, time.Minute)
+	clientErrors.Unlock()
+	defer func() {
+		clientErrors.Lock()
+		clientErrors.groups, clientErrors.limiter = groups, limits
+		clientErrors.Unlock()
+	}()
+
+	handler := HandleClientErrors(Config{Locales: []string{"en", "de"}})
+	report := `{"message": "grouped", "stack": "at a (/js/app.js:1:20)", "url": "https://example.com/de/shop/cart"}`
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/errors", strings.NewReader(report))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/errors", strings.NewReader(`{"stack": ""}`))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	found := false
+	for _, g := range ClientErrors() {
+		if g.Message == "grouped" {
+			found = true
+			assert.Equal(t, int64(2), g.Count)
+			assert.Equal(t, []string{"shop"}, g.Modules)
+		}
+	}
+	assert.True(t, found)
+}
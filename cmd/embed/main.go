@@ -0,0 +1,291 @@
+// Command embed zips a folder of static files into a Go source file that
+// registers the archive with file.RegisterZip when its package is
+// initialized. Typical use is with go generate:
+//
+// 	//go:generate go run github.com/toba/coreweb/cmd/embed -src web -dest static.go -pkg main -gzip
+//
+// Files are added in name order with their modified times so the output only
+// changes when the folder does. Run with -verify to check whether a
+// previously generated file is stale.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/toba/coreweb/file"
+)
+
+var (
+	flagSrc     = flag.String("src", "static", "Folder containing static files.")
+	flagDest    = flag.String("dest", "static.go", "Go source file to write.")
+	flagPkg     = flag.String("pkg", "static", "Package name of the generated source.")
+	flagInclude = flag.String("include", "", "Comma-separated globs of files to include. All files are included if empty.")
+	flagExclude = flag.String("exclude", "", "Comma-separated globs of files to exclude.")
+	flagGZip    = flag.Bool("gzip", false, "Add precompressed copies of compressible files.")
+	flagVerify  = flag.Bool("verify", false, "Report whether the destination is stale instead of writing it.")
+)
+
+func main() {
+	flag.Parse()
+
+	m, err := collect(*flagSrc, globs(*flagInclude), globs(*flagExclude), *flagGZip)
+	exitIfError(err)
+
+	data, err := archive(m)
+	exitIfError(err)
+
+	if *flagVerify {
+		differences, err := verify(*flagDest, data)
+		exitIfError(err)
+
+		if len(differences) > 0 {
+			fmt.Printf("%s is stale:\n", *flagDest)
+			for _, d := range differences {
+				fmt.Println("  " + d)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("%s is current\n", *flagDest)
+		return
+	}
+
+	err = ioutil.WriteFile(*flagDest, generate(*flagPkg, data), 0644)
+	exitIfError(err)
+	log.Printf("Embedded %d files from %s in %s", len(m.Files), *flagSrc, *flagDest)
+}
+
+// globs splits a comma-separated list of glob patterns.
+func globs(list string) []string {
+	patterns := []string{}
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matches indicates whether a slash-separated file name or its base name
+// matches any of the glob patterns.
+func matches(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// collect reads the included files in a folder and optionally GZips those
+// that are compressible. Precompressed .gz copies already in the folder are
+// kept either way.
+func collect(folder string, include, exclude []string, gzip bool) (*file.Map, error) {
+	fsys := os.DirFS(folder)
+	m, err := file.InFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	for name := range m.Files {
+		if (len(include) > 0 && !matches(name, include)) || matches(name, exclude) {
+			delete(m.Files, name)
+		}
+	}
+	if err = m.Read(gzip); err != nil || gzip {
+		return m, err
+	}
+	// the Map pairs .gz copies with their originals but only reads them when
+	// GZipping so they're copied through unchanged here
+	for _, name := range sortedNames(m) {
+		zipped := name + ".gz"
+		f, err := fs.Stat(fsys, zipped)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.ReadFile(fsys, zipped)
+		if err != nil {
+			return nil, err
+		}
+		m.Files[name].Compressed = file.FromBytes(zipped, data, f.ModTime())
+	}
+	return m, nil
+}
+
+// sortedNames returns Map keys in a stable order.
+func sortedNames(m *file.Map) []string {
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// archive zips Map content in name order. Precompressed copies are stored
+// without further compression using the name the file package expects.
+func archive(m *file.Map) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	add := func(name string, method uint16, info *file.Info) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   method,
+			Modified: info.Modified.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(info.Content)
+		return err
+	}
+
+	for _, name := range sortedNames(m) {
+		info := m.Files[name]
+		if err := add(name, zip.Deflate, info); err != nil {
+			return nil, err
+		}
+		if info.Compressed != nil {
+			if err := add(name+".gz", zip.Store, info.Compressed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// generate creates Go source that registers the zip data.
+func generate(pkg string, data []byte) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintln(&buf, "// Code generated by github.com/toba/coreweb/cmd/embed. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintln(&buf, `import "github.com/toba/coreweb/file"`)
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "func init() {")
+	fmt.Fprintf(&buf, "\tfile.RegisterZip(%s)\n", strconv.Quote(string(data)))
+	fmt.Fprintln(&buf, "}")
+
+	return buf.Bytes()
+}
+
+// verify compares the zip entries registered by a generated source file to
+// those that would be generated now, returning a description of each
+// difference.
+func verify(sourceFile string, data []byte) ([]string, error) {
+	existing, err := registeredZip(sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	before, err := entries(existing)
+	if err != nil {
+		return nil, err
+	}
+	after, err := entries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	differences := []string{}
+
+	for name, content := range after {
+		if old, exists := before[name]; !exists {
+			differences = append(differences, "added "+name)
+		} else if !bytes.Equal(old, content) {
+			differences = append(differences, "changed "+name)
+		}
+	}
+	for name := range before {
+		if _, exists := after[name]; !exists {
+			differences = append(differences, "removed "+name)
+		}
+	}
+	sort.Strings(differences)
+
+	return differences, nil
+}
+
+// registeredZip parses a generated source file to retrieve the string passed
+// to file.RegisterZip.
+func registeredZip(sourceFile string) ([]byte, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), sourceFile, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return data == nil
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "RegisterZip" {
+			return true
+		}
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if value, err := strconv.Unquote(lit.Value); err == nil {
+				data = []byte(value)
+			}
+		}
+		return false
+	})
+
+	if data == nil {
+		return nil, fmt.Errorf("no file.RegisterZip data found in %s", sourceFile)
+	}
+	return data, nil
+}
+
+// entries returns the inflated content of each file in zip data.
+func entries(data []byte) (map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = content
+	}
+	return files, nil
+}
+
+// exitIfError logs error if non-nil and exits program.
+func exitIfError(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches(t *testing.T) {
+	patterns := globs("*.map, js/vendor/*")
+
+	assert.True(t, matches("js/app.js.map", patterns))
+	assert.True(t, matches("js/vendor/react.js", patterns))
+	assert.False(t, matches("js/app.js", patterns))
+}
+
+// TestVerify ensures generated output is deterministic and that changes to
+// the folder are reported as stale.
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embed")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	folder := filepath.Join(dir, "static")
+	assert.NoError(t, os.Mkdir(folder, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(folder, "app.js"), []byte(strings.Repeat("var a = 1;", 100)), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(folder, "app.js.map"), []byte("{}"), 0644))
+
+	m, err := collect(folder, nil, []string{"*.map"}, true)
+	assert.NoError(t, err)
+	assert.Len(t, m.Files, 1)
+
+	data, err := archive(m)
+	assert.NoError(t, err)
+
+	again, err := archive(m)
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	dest := filepath.Join(dir, "static.go")
+	assert.NoError(t, ioutil.WriteFile(dest, generate("static", data), 0644))
+
+	differences, err := verify(dest, data)
+	assert.NoError(t, err)
+	assert.Empty(t, differences)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(folder, "app.js"), []byte(strings.Repeat("var a = 2;", 100)), 0644))
+
+	m, err = collect(folder, nil, []string{"*.map"}, true)
+	assert.NoError(t, err)
+	data, err = archive(m)
+	assert.NoError(t, err)
+
+	differences, err = verify(dest, data)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"changed app.js", "changed app.js.gz"}, differences)
+}
+
+// TestPrecompressed ensures .gz copies in the folder are embedded even when
+// not GZipping.
+func TestPrecompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embed")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	zipped := []byte("precompressed by a build tool")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("var a = 1;"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.js.gz"), zipped, 0644))
+
+	m, err := collect(dir, nil, nil, false)
+	assert.NoError(t, err)
+	assert.Len(t, m.Files, 1)
+
+	data, err := archive(m)
+	assert.NoError(t, err)
+	files, err := entries(data)
+	assert.NoError(t, err)
+	assert.Equal(t, zipped, files["app.js.gz"])
+	assert.Equal(t, []byte("var a = 1;"), files["app.js"])
+}
@@ -1,13 +1,127 @@
 package coreweb
 
+import (
+	"io/fs"
+
+	"github.com/toba/coreweb/file"
+)
+
 type Config struct {
 	SslCert    string `json:"sslCert"`    // SslCert is the path and name of the SSL certificate file.
 	SslKey     string `json:"sslKey"`     // SslKey is the path and name of the SSL key file.
-	Port       int    `json:"port"`       // FromZip is the name of a zip file to serve content from rather than the file system.
+	Port       int    `json:"port"`       // Port is the HTTP port to listen on.
+	FromZip    string `json:"fromZip"`    // FromZip is the name of a zip file to serve content from rather than the file system.
 	FromFolder string `json:"fromFolder"` // FromFolder is the folder containing web content.
 
-	// SyncFileAccess indicates if RWMutex lock should be used when reading
-	// the file cache. It should only be true while debugging when files might
-	// be changing while the web server is active.
+	// FS is a file system containing web content, such as an embed.FS. It
+	// takes precedence over FromZip, FromFolder and registered zip data.
+	FS fs.FS `json:"-"`
+
+	// MemoryBudget is the total bytes of static file content, including
+	// GZipped copies, to hold in memory. Least recently used content is
+	// dropped to stay within budget and files are sent uncompressed once
+	// GZipped copies fill it. Zero holds all content in memory.
+	MemoryBudget int64 `json:"memoryBudget"`
+	// MaxMemoryFileSize is the size in bytes above which static files are
+	// always streamed from disk or zip rather than held in memory.
+	MaxMemoryFileSize int64 `json:"maxMemoryFileSize"`
+
+	// Minify removes comments and whitespace from HTML, CSS, JavaScript and
+	// SVG files when they're read. Files with a source map are left as they
+	// are so it stays accurate. Use file.RegisterMinifier to add or replace
+	// transforms for other types.
+	Minify bool `json:"minify"`
+
+	// Compression sets the GZip level, the size and ratio thresholds for
+	// keeping compressed files and how many files to compress at once.
+	Compression file.Compression `json:"compression"`
+
+	// TrailingSlash indicates that canonical page paths, those without a file
+	// extension, end with a slash. Requests for other forms of a path are
+	// redirected to the canonical one.
+	TrailingSlash bool `json:"trailingSlash"`
+
+	// FallbackModule is the module path that renders requests for unknown
+	// paths without a file extension. If empty such requests are not found.
+	FallbackModule string `json:"fallbackModule"`
+
+	// Locales are the supported locales, such as "en" or "de-AT", the first
+	// being the default. Requests are served in the locale of a path prefix
+	// like /de/app, the LocaleCookie or the Accept-Language header. Module
+	// pages render template.<locale>.html, if present, and other files are
+	// served from localized variants like strings.<locale>.json.
+	Locales []string `json:"locales"`
+	// LocaleCookie names the cookie holding a chosen locale. The default is
+	// "locale".
+	LocaleCookie string `json:"localeCookie"`
+
+	// Offline serves a service worker at /sw.js that precaches module pages
+	// and static files so modules keep working without a connection. It's
+	// regenerated whenever static files change.
+	Offline bool `json:"offline"`
+	// Manifest, if set, is served at /manifest.webmanifest so the site can
+	// be installed as a web app.
+	Manifest *Manifest `json:"manifest"`
+
+	// Integrity adds integrity and crossorigin attributes to template script
+	// and link tags that load local scripts and style sheets. Digests can
+	// also be placed in the template with tokens like {integrity:/js/app.js}.
+	Integrity bool `json:"integrity"`
+
+	// ImportMap is the path within the static files of a manifest mapping
+	// bare module specifiers to script URLs and naming the entry script of
+	// each module path. Module pages get an import map with fingerprinted
+	// URLs and modulepreload links for the entry script's imports.
+	ImportMap string `json:"importMap"`
+
+	// Preload lists the URLs of critical assets for each module path. They're
+	// sent as Link preload headers with module pages, followed by the
+	// scripts and style sheets the template loads.
+	Preload map[string][]string `json:"preload"`
+	// EarlyHints sends the preload Link headers in a 103 Early Hints
+	// response before each module page.
+	EarlyHints bool `json:"earlyHints"`
+
+	// BaseURL is the canonical scheme and host of the site, such as
+	// "https://example.com". When set, robots.txt and sitemap.xml are
+	// generated from the module paths unless present in the static files.
+	BaseURL string `json:"baseURL"`
+	// Pages are search engine settings keyed by module path.
+	Pages map[string]Page `json:"pages"`
+
+	// ImagePresets are the allowed transforms of PNG, JPEG and GIF images,
+	// requested as ?preset=<name>. Other transform parameters are refused.
+	ImagePresets map[string]ImagePreset `json:"imagePresets"`
+	// ImageCacheSize is the bytes of transformed images to hold in memory.
+	// The default is 32MB.
+	ImageCacheSize int64 `json:"imageCacheSize"`
+
+	// ReportLimit is how many violation or error reports each client
+	// address may send a minute. The default is 30.
+	ReportLimit int `json:"reportLimit"`
+
+	// SyncFileAccess indicates if the file cache should be updated when files
+	// change while the web server is active. Updates are swapped in as new
+	// cache snapshots so requests are never blocked, making this safe outside
+	// of debugging as well. Connected browsers are sent socket reload notices
+	// when files change.
 	SyncFileAccess bool
+
+	// BundleKeys are base64 encoded Ed25519 public keys. A static bundle
+	// uploaded at runtime must be signed by one of them.
+	BundleKeys []string `json:"bundleKeys"`
+	// BundleHistory is how many uploaded bundles to keep for rollback. Zero
+	// keeps three.
+	BundleHistory int `json:"bundleHistory"`
+
+	// AdminAllow lists IP addresses or CIDR ranges allowed to use admin
+	// endpoints in addition to loopback addresses.
+	AdminAllow []string `json:"adminAllow"`
+	// MaintenanceAllow lists IP addresses or CIDR ranges that can still use
+	// the site in maintenance mode.
+	MaintenanceAllow []string `json:"maintenanceAllow"`
+
+	// AdminPermission is the authorization token permission required to use
+	// admin endpoints.
+	AdminPermission uint16 `json:"adminPermission"`
 }
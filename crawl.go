@@ -0,0 +1,150 @@
+package coreweb
+
+import (
+	"encoding/xml"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toba/coreweb/file"
+)
+
+const (
+	robotsPath  = "robots.txt"
+	sitemapPath = "sitemap.xml"
+	sitemapNS   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapDate = "2006-01-02"
+)
+
+type (
+	// Page describes how search engines should treat a module path.
+	Page struct {
+		// NoIndex excludes the module from the sitemap and disallows it in
+		// robots.txt. Modules are indexable by default.
+		NoIndex bool `json:"noIndex"`
+		// ChangeFrequency is how often the page is expected to change, such
+		// as "daily" or "monthly".
+		ChangeFrequency string `json:"changeFrequency"`
+		// Priority of the page relative to others on the site from 0 to 1.
+		Priority float64 `json:"priority"`
+	}
+
+	sitemapURL struct {
+		Location        string `xml:"loc"`
+		LastModified    string `xml:"lastmod,omitempty"`
+		ChangeFrequency string `xml:"changefreq,omitempty"`
+		Priority        string `xml:"priority,omitempty"`
+	}
+
+	urlSet struct {
+		XMLName   xml.Name     `xml:"urlset"`
+		Namespace string       `xml:"xmlns,attr"`
+		URLs      []sitemapURL `xml:"url"`
+	}
+)
+
+// generateCrawl creates robots.txt and sitemap.xml for a cache snapshot from
+// the module paths and their Page settings. Nothing is generated without a
+// BaseURL since sitemap locations must be absolute.
+func generateCrawl(c Config, m *file.Map, modules routes) map[string]*file.Info {
+	files := make(map[string]*file.Info)
+	if c.BaseURL == "" {
+		return files
+	}
+	now := time.Now()
+	base := strings.TrimSuffix(c.BaseURL, webSlash)
+
+	data, err := renderSitemap(c, m, modules, base)
+	if err != nil {
+		log.Printf("Unable to create sitemap: %v", err)
+	} else {
+		files[sitemapPath] = file.FromBytes(sitemapPath, data, now)
+	}
+	files[robotsPath] = file.FromBytes(robotsPath, renderRobots(c, modules, base), now)
+
+	return files
+}
+
+// modulePath is the canonical URL path of a module page.
+func modulePath(c Config, module string) string {
+	p := webSlash + module
+	if c.TrailingSlash && module != "" {
+		p += webSlash
+	}
+	return p
+}
+
+// renderSitemap lists indexable module pages in name order.
+func renderSitemap(c Config, m *file.Map, modules routes, base string) ([]byte, error) {
+	set := urlSet{Namespace: sitemapNS, URLs: []sitemapURL{}}
+
+	for _, module := range sorted(modules) {
+		page := c.Pages[module]
+		if page.NoIndex {
+			continue
+		}
+		u := sitemapURL{
+			Location:        base + modulePath(c, module),
+			ChangeFrequency: page.ChangeFrequency,
+		}
+		if page.Priority > 0 {
+			u.Priority = strconv.FormatFloat(page.Priority, 'f', 1, 64)
+		}
+		if info, exists := m.Files[module]; exists && !info.Modified.IsZero() {
+			u.LastModified = info.Modified.UTC().Format(sitemapDate)
+		}
+		set.URLs = append(set.URLs, u)
+	}
+	data, err := xml.MarshalIndent(set, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// sorted lists module paths in name order.
+func sorted(modules routes) []string {
+	paths := append([]string{}, modules...)
+	sort.Strings(paths)
+	return paths
+}
+
+// renderRobots allows all crawlers everywhere except modules marked
+// NoIndex, in any locale, and points them to the sitemap.
+func renderRobots(c Config, modules routes, base string) []byte {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+
+	disallowed := 0
+	for _, module := range sorted(modules) {
+		if !c.Pages[module].NoIndex {
+			continue
+		}
+		prefixes := []string{""}
+		for _, locale := range c.Locales {
+			prefixes = append(prefixes, webSlash+locale)
+		}
+		for _, prefix := range prefixes {
+			p := prefix + webSlash + module
+			if module == "" {
+				// only the root page rather than the whole site
+				b.WriteString("Disallow: " + p + "$\n")
+				disallowed++
+				continue
+			}
+			// rules match by prefix so the bare path and those beneath it
+			// are listed apart from others sharing its start
+			b.WriteString("Disallow: " + p + "$\n")
+			b.WriteString("Disallow: " + p + "/\n")
+			disallowed += 2
+		}
+	}
+	if disallowed == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	b.WriteString("\nSitemap: " + base + webSlash + sitemapPath + "\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,63 @@
+package coreweb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+func TestGenerateCrawl(t *testing.T) {
+	m := offlineMap(t, "var a = 1;")
+	modules := newRoutes([]string{"app", "admin"})
+
+	assert.Empty(t, generateCrawl(Config{}, m, modules))
+
+	c := Config{
+		BaseURL:       "https://example.com/",
+		TrailingSlash: true,
+		Locales:       []string{"en", "de"},
+		Pages: map[string]Page{
+			"app":   {ChangeFrequency: "daily", Priority: 0.8},
+			"admin": {NoIndex: true},
+		},
+	}
+	files := generateCrawl(c, m, modules)
+
+	robots := files[robotsPath]
+	assert.Equal(t, mime.Text, robots.Header[content.Type])
+	assert.Equal(t, "User-agent: *\n"+
+		"Disallow: /admin$\n"+
+		"Disallow: /admin/\n"+
+		"Disallow: /en/admin$\n"+
+		"Disallow: /en/admin/\n"+
+		"Disallow: /de/admin$\n"+
+		"Disallow: /de/admin/\n"+
+		"\nSitemap: https://example.com/sitemap.xml\n", string(robots.Content))
+
+	sitemap := string(files[sitemapPath].Content)
+	assert.Contains(t, sitemap, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	assert.Contains(t, sitemap, "<loc>https://example.com/app/</loc>")
+	assert.Contains(t, sitemap, "<changefreq>daily</changefreq>")
+	assert.Contains(t, sitemap, "<priority>0.8</priority>")
+	assert.NotContains(t, sitemap, "admin")
+}
+
+// TestRobotsSharedPrefix ensures hiding a module doesn't hide another whose
+// path begins the same way.
+func TestRobotsSharedPrefix(t *testing.T) {
+	c := Config{Pages: map[string]Page{"app": {NoIndex: true}}}
+	robots := string(renderRobots(c, newRoutes([]string{"app", "application"}), "https://example.com"))
+
+	assert.Equal(t, "User-agent: *\n"+
+		"Disallow: /app$\n"+
+		"Disallow: /app/\n"+
+		"\nSitemap: https://example.com/sitemap.xml\n", robots)
+	assert.NotContains(t, robots, "Disallow: /app\n")
+}
+
+func TestRobotsAllowAll(t *testing.T) {
+	robots := renderRobots(Config{}, newRoutes([]string{"app"}), "https://example.com")
+	assert.Equal(t, "User-agent: *\nDisallow:\n\nSitemap: https://example.com/sitemap.xml\n", string(robots))
+}
@@ -0,0 +1,329 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+const (
+	// maxReportSize is the largest report body accepted.
+	maxReportSize = 64 << 10
+	// defaultReportLimit is how many reports each client may send a minute
+	// when Config.ReportLimit isn't set.
+	defaultReportLimit = 30
+	// maxViolationKinds is how many distinct violations are tracked. Others
+	// are counted but not logged or forwarded.
+	maxViolationKinds = 1000
+	// maxReportField is the most characters kept of reported URLs and
+	// samples.
+	maxReportField   = 256
+	cspViolationType = "csp-violation"
+)
+
+type (
+	// Violation is a Content-Security-Policy violation reported by a
+	// browser.
+	Violation struct {
+		DocumentURL string `json:"documentURL"`
+		Referrer    string `json:"referrer,omitempty"`
+		// Directive is the policy directive that was violated, such as
+		// "script-src-elem".
+		Directive string `json:"directive"`
+		// BlockedURL is the source that was blocked, or a keyword such as
+		// "inline" or "eval".
+		BlockedURL  string `json:"blockedURL"`
+		SourceFile  string `json:"sourceFile,omitempty"`
+		Line        int    `json:"line,omitempty"`
+		Column      int    `json:"column,omitempty"`
+		Sample      string `json:"sample,omitempty"`
+		Disposition string `json:"disposition,omitempty"`
+		// Count is how many times the directive and source have been
+		// reported.
+		Count int64 `json:"count"`
+	}
+
+	// cspReport is the body of an application/csp-report submission.
+	cspReport struct {
+		Report struct {
+			DocumentURI        string `json:"document-uri"`
+			Referrer           string `json:"referrer"`
+			ViolatedDirective  string `json:"violated-directive"`
+			EffectiveDirective string `json:"effective-directive"`
+			BlockedURI         string `json:"blocked-uri"`
+			SourceFile         string `json:"source-file"`
+			Line               int    `json:"line-number"`
+			Column             int    `json:"column-number"`
+			Sample             string `json:"script-sample"`
+			Disposition        string `json:"disposition"`
+		} `json:"csp-report"`
+	}
+
+	// apiReport is one entry of an application/reports+json submission from
+	// the Reporting API.
+	apiReport struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			Referrer           string `json:"referrer"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+			SourceFile         string `json:"sourceFile"`
+			Line               int    `json:"lineNumber"`
+			Column             int    `json:"columnNumber"`
+			Sample             string `json:"sample"`
+			Disposition        string `json:"disposition"`
+		} `json:"body"`
+	}
+)
+
+var (
+	errReportType   = errors.New("report must be application/csp-report or application/reports+json")
+	errReportFields = errors.New("report must include the document URL and directive")
+
+	// directivePattern matches policy directive names like "script-src".
+	directivePattern = regexp.MustCompile(`^[a-z][a-z-]{0,39}$`)
+
+	// violations are the distinct violations reported, keyed by directive
+	// and source.
+	violations = struct {
+		sync.Mutex
+		seen        map[string]*Violation
+		subscribers []func(Violation)
+		// outcomes counts reports by how they were handled and directives
+		// counts violations of each tracked directive.
+		outcomes   map[string]int64
+		directives map[string]int64
+	}{
+		seen:       make(map[string]*Violation),
+		outcomes:   make(map[string]int64),
+		directives: make(map[string]int64),
+	}
+)
+
+// ViolationCounts are totals of the reports received.
+type ViolationCounts struct {
+	// Reports counts reports as accepted, duplicate, untracked, limited or
+	// rejected.
+	Reports map[string]int64 `json:"reports"`
+	// Directives counts violations of each tracked directive.
+	Directives map[string]int64 `json:"directives"`
+}
+
+// OnViolation calls fn the first time each distinct violation, identified
+// by its directive and blocked source, is reported.
+func OnViolation(fn func(Violation)) {
+	violations.Lock()
+	defer violations.Unlock()
+
+	violations.subscribers = append(violations.subscribers, fn)
+}
+
+// Violations lists the distinct violations reported with how many times
+// each was reported.
+func Violations() []Violation {
+	violations.Lock()
+	defer violations.Unlock()
+
+	list := make([]Violation, 0, len(violations.seen))
+	for _, v := range violations.seen {
+		list = append(list, *v)
+	}
+	return list
+}
+
+// ViolationTotals returns counts of reports by outcome and of violations by
+// directive.
+func ViolationTotals() ViolationCounts {
+	violations.Lock()
+	defer violations.Unlock()
+
+	counts := ViolationCounts{
+		Reports:    make(map[string]int64, len(violations.outcomes)),
+		Directives: make(map[string]int64, len(violations.directives)),
+	}
+	for k, n := range violations.outcomes {
+		counts.Reports[k] = n
+	}
+	for k, n := range violations.directives {
+		counts.Directives[k] = n
+	}
+	return counts
+}
+
+// countReport adds to the count of reports with an outcome.
+func countReport(outcome string) {
+	violations.Lock()
+	violations.outcomes[outcome]++
+	violations.Unlock()
+}
+
+// HandleViolations accepts Content-Security-Policy violation reports sent as
+// application/csp-report by the report-uri directive or as
+// application/reports+json by the Reporting API. Submissions are limited to
+// Config.ReportLimit a minute for each client address.
+func HandleViolations(c Config) func(w http.ResponseWriter, r *http.Request) {
+	limit := c.ReportLimit
+	if limit <= 0 {
+		limit = defaultReportLimit
+	}
+	limiter := newLimiter(limit, time.Minute)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !limiter.allow(clientAddress(r)) {
+			countReport("limited")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReportSize+1))
+		if err != nil || len(data) > maxReportSize {
+			countReport("rejected")
+			http.Error(w, "Report is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		reports, err := parseViolations(r.Header.Get(content.Type), data)
+		if err != nil {
+			countReport("rejected")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, v := range reports {
+			recordViolation(v)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseViolations reads violations from a report body of the given type.
+// Reports of other kinds sent through the Reporting API are ignored.
+func parseViolations(contentType string, data []byte) ([]Violation, error) {
+	list := []Violation{}
+
+	switch mime.Base(contentType) {
+	case mime.CSPReport:
+		report := cspReport{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, err
+		}
+		r := report.Report
+		directive := r.EffectiveDirective
+		if fields := strings.Fields(r.ViolatedDirective); directive == "" && len(fields) > 0 {
+			// older browsers only send the violated directive and its value
+			directive = fields[0]
+		}
+		list = append(list, Violation{
+			DocumentURL: r.DocumentURI,
+			Referrer:    r.Referrer,
+			Directive:   directive,
+			BlockedURL:  r.BlockedURI,
+			SourceFile:  r.SourceFile,
+			Line:        r.Line,
+			Column:      r.Column,
+			Sample:      r.Sample,
+			Disposition: r.Disposition,
+		})
+
+	case mime.Reports:
+		reports := []apiReport{}
+		if err := json.Unmarshal(data, &reports); err != nil {
+			return nil, err
+		}
+		for _, r := range reports {
+			if r.Type != cspViolationType {
+				continue
+			}
+			list = append(list, Violation{
+				DocumentURL: r.Body.DocumentURL,
+				Referrer:    r.Body.Referrer,
+				Directive:   r.Body.EffectiveDirective,
+				BlockedURL:  r.Body.BlockedURL,
+				SourceFile:  r.Body.SourceFile,
+				Line:        r.Body.Line,
+				Column:      r.Body.Column,
+				Sample:      r.Body.Sample,
+				Disposition: r.Body.Disposition,
+			})
+		}
+
+	default:
+		return nil, errReportType
+	}
+
+	for i, v := range list {
+		if !directivePattern.MatchString(v.Directive) || v.DocumentURL == "" {
+			return nil, errReportFields
+		}
+		// long values would let a few reports hold a lot of memory
+		list[i].DocumentURL = truncate(v.DocumentURL)
+		list[i].Referrer = truncate(v.Referrer)
+		list[i].BlockedURL = truncate(v.BlockedURL)
+		list[i].SourceFile = truncate(v.SourceFile)
+		list[i].Sample = truncate(v.Sample)
+	}
+	return list, nil
+}
+
+// recordViolation counts a violation, logging it and telling subscribers
+// the first time its directive and source are seen.
+func recordViolation(v Violation) {
+	key := v.Directive + " " + v.BlockedURL
+
+	violations.Lock()
+	violations.outcomes["accepted"]++
+
+	if seen, ok := violations.seen[key]; ok {
+		seen.Count++
+		violations.outcomes["duplicate"]++
+		violations.directives[v.Directive]++
+		violations.Unlock()
+		return
+	}
+	if len(violations.seen) >= maxViolationKinds {
+		violations.outcomes["untracked"]++
+		// only directives already tracked are counted so keys stay bounded
+		if _, ok := violations.directives[v.Directive]; ok {
+			violations.directives[v.Directive]++
+		}
+		violations.Unlock()
+		return
+	}
+	v.Count = 1
+	violations.seen[key] = &v
+	violations.directives[v.Directive]++
+	subscribers := violations.subscribers
+	violations.Unlock()
+
+	log.Printf("Content-Security-Policy %s blocked %s on %s", v.Directive, v.BlockedURL, v.DocumentURL)
+
+	for _, fn := range subscribers {
+		fn(v)
+	}
+}
+
+// truncate shortens a reported value to at most maxReportField bytes
+// without splitting a multi-byte character.
+func truncate(s string) string {
+	if len(s) <= maxReportField {
+		return s
+	}
+	end := maxReportField
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
@@ -0,0 +1,134 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+func postReport(handler http.HandlerFunc, contentType, body string) int {
+	r := httptest.NewRequest(http.MethodPost, "/csp", strings.NewReader(body))
+	r.Header.Set(content.Type, contentType)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func TestParseViolations(t *testing.T) {
+	list, err := parseViolations(mime.CSPReport, []byte(`{"csp-report": {
+		"document-uri": "https://example.com/app",
+		"violated-directive": "script-src 'self'",
+		"blocked-uri": "https://evil.example.com/x.js",
+		"line-number": 3
+	}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Violation{{
+		DocumentURL: "https://example.com/app",
+		Directive:   "script-src",
+		BlockedURL:  "https://evil.example.com/x.js",
+		Line:        3,
+	}}, list)
+
+	list, err = parseViolations(mime.Reports+"; charset=utf-8", []byte(`[
+		{"type": "deprecation", "body": {}},
+		{"type": "csp-violation", "body": {
+			"documentURL": "https://example.com/app",
+			"effectiveDirective": "style-src-elem",
+			"blockedURL": "inline"
+		}}
+	]`))
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "style-src-elem", list[0].Directive)
+
+	_, err = parseViolations(mime.JSON, []byte(`{}`))
+	assert.Equal(t, errReportType, err)
+
+	_, err = parseViolations(mime.CSPReport, []byte(`{"csp-report": {"document-uri": "https://example.com"}}`))
+	assert.Equal(t, errReportFields, err)
+
+	_, err = parseViolations(mime.CSPReport, []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestHandleViolations(t *testing.T) {
+	violations.Lock()
+	seen, subscribers := violations.seen, violations.subscribers
+	outcomes, directives := violations.outcomes, violations.directives
+	violations.seen = make(map[string]*Violation)
+	violations.subscribers = nil
+	violations.outcomes = make(map[string]int64)
+	violations.directives = make(map[string]int64)
+	violations.Unlock()
+
+	defer func() {
+		violations.Lock()
+		violations.seen, violations.subscribers = seen, subscribers
+		violations.outcomes, violations.directives = outcomes, directives
+		violations.Unlock()
+	}()
+	handler := HandleViolations(Config{ReportLimit: 3})
+	forwarded := []Violation{}
+	OnViolation(func(v Violation) { forwarded = append(forwarded, v) })
+
+	report := `{"csp-report": {
+		"document-uri": "https://example.com/app",
+		"effective-directive": "img-src",
+		"blocked-uri": "https://tracker.example.com/pixel.gif"
+	}}`
+	assert.Equal(t, http.StatusNoContent, postReport(handler, mime.CSPReport, report))
+	assert.Equal(t, http.StatusNoContent, postReport(handler, mime.CSPReport, report))
+	assert.Equal(t, http.StatusBadRequest, postReport(handler, mime.Text, report))
+	assert.Equal(t, http.StatusTooManyRequests, postReport(handler, mime.CSPReport, report))
+
+	// duplicates are counted but forwarded once
+	assert.Len(t, forwarded, 1)
+	assert.Equal(t, "img-src", forwarded[0].Directive)
+
+	list := Violations()
+	assert.Len(t, list, 1)
+	assert.Equal(t, int64(2), list[0].Count)
+
+	counts := ViolationTotals()
+	assert.Equal(t, int64(2), counts.Directives["img-src"])
+	assert.Equal(t, int64(1), counts.Reports["limited"])
+	assert.Equal(t, int64(1), counts.Reports["duplicate"])
+}
+
+func TestViolationCountsBounded(t *testing.T) {
+	violations.Lock()
+	seen := violations.seen
+	violations.seen = make(map[string]*Violation, maxViolationKinds)
+	for i := 0; i < maxViolationKinds; i++ {
+		violations.seen[strconv.Itoa(i)] = &Violation{}
+	}
+	violations.Unlock()
+
+	defer func() {
+		violations.Lock()
+		violations.seen = seen
+		violations.Unlock()
+	}()
+	recordViolation(Violation{Directive: "untracked-src", BlockedURL: "https://example.com"})
+
+	_, counted := ViolationTotals().Directives["untracked-src"]
+	assert.False(t, counted)
+}
+
+func TestTruncate(t *testing.T) {
+	short := strings.Repeat("a", maxReportField)
+	assert.Equal(t, short, truncate(short))
+
+	// a two byte character straddling the limit is dropped whole
+	long := strings.Repeat("a", maxReportField-1) + "é" + "b"
+	cut := truncate(long)
+	assert.Equal(t, strings.Repeat("a", maxReportField-1), cut)
+	assert.True(t, utf8.ValidString(cut))
+}
@@ -0,0 +1,356 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toba/coreweb/auth"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+const statePath = webSlash + "state"
+
+var (
+	// started is when the process began serving, for reporting uptime.
+	started = time.Now()
+
+	errNoStatic = errors.New("Static files are not loaded")
+
+	// inspection holds dashboard sections and actions registered by other
+	// packages, such as socket clients and service statistics.
+	inspection = struct {
+		sync.RWMutex
+		sections map[string]func() interface{}
+		actions  map[string]func(r *http.Request) error
+	}{
+		sections: make(map[string]func() interface{}),
+		actions:  make(map[string]func(r *http.Request) error),
+	}
+)
+
+type (
+	// cachedFile describes a static file for the dashboard.
+	cachedFile struct {
+		Path       string    `json:"path"`
+		Type       string    `json:"type"`
+		Size       int64     `json:"size"`
+		Compressed int64     `json:"compressed,omitempty"`
+		Modified   time.Time `json:"modified"`
+	}
+
+	// providerState describes an authentication provider for the dashboard.
+	providerState struct {
+		ID      auth.AuthProviderID `json:"id"`
+		Key     string              `json:"key"`
+		Enabled bool                `json:"enabled"`
+	}
+)
+
+// Inspect adds a section to the admin dashboard. The function is called for
+// each dashboard request and its result shown as JSON.
+func Inspect(name string, fn func() interface{}) {
+	inspection.Lock()
+	defer inspection.Unlock()
+
+	inspection.sections[name] = fn
+}
+
+// AdminAction adds an action that may be POSTed to the admin dashboard at
+// its mount path followed by /<name>.
+func AdminAction(name string, fn func(r *http.Request) error) {
+	inspection.Lock()
+	defer inspection.Unlock()
+
+	inspection.actions[name] = fn
+}
+
+func init() {
+	AdminAction("purge", purgeCaches)
+	AdminAction("reload", reloadStatic)
+}
+
+// HandleAdmin serves a dashboard showing cached files, authentication
+// providers, runtime statistics and sections added with Inspect, such as
+// connected socket clients and service calls. Clients must have an address
+// allowed by Config.AdminAllow. The dashboard page asks for a token with
+// Config.AdminPermission which it sends to retrieve the state, at the mount
+// path followed by /state, and to POST actions like /purge and /reload.
+func HandleAdmin(c Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowAddress(c.AdminAllow, r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodGet && !strings.HasSuffix(r.URL.Path, statePath) {
+			// the page holds no data so only the address is checked
+			w.Header().Set(content.Type, mime.HTML)
+			w.Write([]byte(dashboardPage))
+			return
+		}
+		if !hasPermission(r, c.AdminPermission) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			name := path.Base(r.URL.Path)
+			inspection.RLock()
+			action, ok := inspection.actions[name]
+			inspection.RUnlock()
+
+			if !ok {
+				http.Error(w, "No action named "+name, http.StatusNotFound)
+				return
+			}
+			if err := action(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Ran admin action %s for %s", name, clientAddress(r))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(dashboardState())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(content.Type, mime.JSON)
+		w.Write(data)
+	}
+}
+
+// dashboardState collects all dashboard sections.
+func dashboardState() map[string]interface{} {
+	state := map[string]interface{}{
+		"files":        cachedFiles(),
+		"auth":         providerStates(),
+		"runtime":      runtimeStats(),
+		"maintenance":  CurrentMaintenance(),
+		"violations":   Violations(),
+		"cspReports":   ViolationTotals(),
+		"clientErrors": ClientErrors(),
+	}
+	actions := []string{}
+
+	inspection.RLock()
+	for name, fn := range inspection.sections {
+		state[name] = fn()
+	}
+	for name := range inspection.actions {
+		actions = append(actions, name)
+	}
+	inspection.RUnlock()
+
+	sort.Strings(actions)
+	state["actions"] = actions
+
+	return state
+}
+
+// cachedFiles lists the files in the current snapshot in path order.
+func cachedFiles() []cachedFile {
+	files := []cachedFile{}
+	if static == nil {
+		return files
+	}
+	m := static.cache.Map()
+
+	for key, info := range m.Files {
+		f := cachedFile{
+			Path:     webSlash + key,
+			Type:     info.Header[content.Type],
+			Size:     info.Size,
+			Modified: info.Modified,
+		}
+		if info.Compressed != nil {
+			f.Compressed = info.Compressed.Size
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	return files
+}
+
+// providerStates lists every configured authentication provider.
+func providerStates() []providerState {
+	list := []providerState{}
+	for _, p := range auth.AllProviders() {
+		list = append(list, providerState{ID: p.ID, Key: p.Key, Enabled: p.Enabled})
+	}
+	return list
+}
+
+// runtimeStats summarizes the process and its memory use.
+func runtimeStats() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := map[string]interface{}{
+		"version":    runtime.Version(),
+		"uptime":     time.Since(started).Round(time.Second).String(),
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"heapBytes":  mem.HeapAlloc,
+		"sysBytes":   mem.Sys,
+		"gcRuns":     mem.NumGC,
+		"gcPause":    time.Duration(mem.PauseTotalNs).String(),
+	}
+	if static != nil {
+		m := static.cache.Map()
+		stats["fileCount"] = len(m.Files)
+		stats["fileMemory"] = m.MemoryUsed()
+		stats["bundleVersion"] = m.Version
+	}
+	return stats
+}
+
+// purgeCaches drops file content held within the memory budget, transformed
+// images and parsed source maps so they're created again as needed.
+func purgeCaches(r *http.Request) error {
+	if static == nil {
+		return errNoStatic
+	}
+	static.cache.Map().Purge()
+	if static.images != nil {
+		static.images.purge()
+	}
+
+	sourceMaps.Lock()
+	sourceMaps.m = nil
+	sourceMaps.Unlock()
+
+	log.Print("Purged static file caches")
+	return nil
+}
+
+// reloadStatic reads the configured static files again and swaps them in,
+// replacing any uploaded bundle.
+func reloadStatic(r *http.Request) error {
+	if static == nil {
+		return errNoStatic
+	}
+	m, err := load(static.config, static.modulePaths)
+	if err != nil {
+		return err
+	}
+	bundles.Lock()
+	bundles.history = nil
+	bundles.Unlock()
+
+	static.cache.Replace(m)
+	log.Print("Reloaded static files")
+	return nil
+}
+
+// dashboardPage asks for an admin token then shows each section of the
+// dashboard state as a table, with buttons for actions. Socket clients can
+// be disconnected individually.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Admin</title>
+<style>
+   body { font-family: sans-serif; margin: 1em 2em; }
+   table { border-collapse: collapse; margin-bottom: 2em; }
+   th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; font-size: 0.9em; }
+   h2 { text-transform: capitalize; }
+</style>
+</head>
+<body>
+<h1>Admin</h1>
+<div id="actions"></div>
+<div id="state"></div>
+<script>
+const base = location.pathname.replace(/\/$/, "");
+let token = sessionStorage.getItem("adminToken") || prompt("Admin token");
+sessionStorage.setItem("adminToken", token);
+
+function request(method, path, body) {
+   return fetch(base + path, {
+      method,
+      body,
+      headers: { "Authorization": "Bearer " + token }
+   }).then(res => {
+      if (!res.ok) {
+         return res.text().then(text => { throw new Error(text); });
+      }
+      return res.json();
+   }).then(render, err => alert(err.message));
+}
+
+function act(name, id) {
+   const form = new URLSearchParams();
+   if (id !== undefined) { form.set("id", id); }
+   request("POST", "/" + name, form);
+}
+
+function cell(value) {
+   const td = document.createElement("td");
+   td.textContent = typeof value === "object" && value !== null ? JSON.stringify(value) : value;
+   return td;
+}
+
+function table(name, value) {
+   const t = document.createElement("table");
+   const rows = Array.isArray(value) ? value : [value];
+   if (rows.length === 0) {
+      t.appendChild(document.createElement("tr")).appendChild(cell("None"));
+      return t;
+   }
+   if (typeof rows[0] !== "object" || rows[0] === null) {
+      rows.forEach(v => t.appendChild(document.createElement("tr")).appendChild(cell(v)));
+      return t;
+   }
+   const keys = Object.keys(rows[0]);
+   const head = t.appendChild(document.createElement("tr"));
+   keys.forEach(k => head.appendChild(document.createElement("th")).textContent = k);
+
+   rows.forEach(row => {
+      const tr = t.appendChild(document.createElement("tr"));
+      keys.forEach(k => tr.appendChild(cell(row[k])));
+      if (name === "clients") {
+         const button = tr.appendChild(document.createElement("td")).appendChild(document.createElement("button"));
+         button.textContent = "Disconnect";
+         button.onclick = () => act("disconnect", row.id);
+      }
+   });
+   return t;
+}
+
+function render(state) {
+   const actions = document.getElementById("actions");
+   actions.textContent = "";
+   state.actions.filter(a => a !== "disconnect").forEach(name => {
+      const button = actions.appendChild(document.createElement("button"));
+      button.textContent = name;
+      button.onclick = () => act(name);
+   });
+   delete state.actions;
+
+   const root = document.getElementById("state");
+   root.textContent = "";
+   Object.keys(state).sort().forEach(name => {
+      root.appendChild(document.createElement("h2")).textContent = name;
+      root.appendChild(table(name, state[name]));
+   });
+}
+
+request("GET", "/state");
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,90 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+var dashboardFiles = fstest.MapFS{
+	"html/template.html": {Data: []byte(`<html><head></head><body>{name}</body></html>`)},
+	"css/app.css":        {Data: []byte("body { margin: 0; }")},
+}
+
+func TestHandleAdmin(t *testing.T) {
+	handler := HandleAdmin(Config{AdminAllow: []string{"10.0.0.0/8"}})
+
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.RemoteAddr = "192.168.1.5:4000"
+	w := httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.RemoteAddr = "10.1.2.3:4000"
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Admin</h1>")
+
+	// state and actions also need an admin token
+	r = httptest.NewRequest(http.MethodGet, "/admin/state", nil)
+	r.RemoteAddr = "127.0.0.1:4000"
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/admin/purge", nil)
+	r.RemoteAddr = "127.0.0.1:4000"
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestDashboardState(t *testing.T) {
+	previous := static
+	defer func() { static = previous }()
+
+	c := Config{FS: dashboardFiles}
+	m, err := load(c, []string{"app"})
+	assert.NoError(t, err)
+	static = &site{config: c, modulePaths: []string{"app"}, cache: file.NewCache(m)}
+
+	Inspect("dashboardTest", func() interface{} { return "inspected" })
+	state := dashboardState()
+
+	assert.Equal(t, "inspected", state["dashboardTest"])
+	assert.Contains(t, state["actions"], "purge")
+	assert.Contains(t, state["actions"], "reload")
+
+	files := state["files"].([]cachedFile)
+	assert.Len(t, files, 2)
+	assert.Equal(t, "/app", files[0].Path)
+	assert.Equal(t, "/css/app.css", files[1].Path)
+	assert.Equal(t, int64(len("body { margin: 0; }")), files[1].Size)
+
+	assert.Equal(t, 2, state["runtime"].(map[string]interface{})["fileCount"])
+}
+
+func TestAdminActions(t *testing.T) {
+	previous := static
+	defer func() { static = previous }()
+
+	static = nil
+	assert.Equal(t, errNoStatic, purgeCaches(nil))
+	assert.Equal(t, errNoStatic, reloadStatic(nil))
+
+	c := Config{FS: dashboardFiles}
+	m, err := load(c, []string{"app"})
+	assert.NoError(t, err)
+	static = &site{config: c, modulePaths: []string{"app"}, cache: file.NewCache(m)}
+
+	assert.NoError(t, purgeCaches(nil))
+	assert.NoError(t, reloadStatic(nil))
+	assert.NotSame(t, m, static.cache.Map())
+	assert.Contains(t, static.cache.Map().Files, "app")
+}
@@ -0,0 +1,91 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+const (
+	// errorTemplatePath is rendered for errors without their own page, such
+	// as 404.html, in the static files.
+	errorTemplatePath = "error.html"
+	statusToken       = "{status}"
+	messageToken      = "{message}"
+)
+
+// errorResponse is the JSON body of an error.
+type errorResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// writeError responds with the static page for an error status, such as
+// 404.html, or with error.html rendered for the status. Clients preferring
+// JSON receive the status and message as JSON and those without a page as
+// plain text. The message is escaped when rendered in an HTML page.
+func writeError(w http.ResponseWriter, r *http.Request, m *file.Map, status int, message string) {
+	// clear headers set for a file that failed to be sent
+	w.Header().Del(content.Encoding)
+	w.Header().Del(content.Length)
+	w.Header().Set(header.ContentTypeOptions, "nosniff")
+
+	if prefersJSON(r) {
+		body, _ := json.Marshal(errorResponse{Status: status, Message: message})
+		w.Header().Set(content.Type, mime.JSON)
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	if m != nil {
+		if page, ok := errorPage(m, status, message); ok {
+			w.Header().Set(content.Type, mime.HTML)
+			w.Header().Set(content.Length, strconv.Itoa(len(page)))
+			w.WriteHeader(status)
+			w.Write(page)
+			return
+		}
+	}
+	http.Error(w, message, status)
+}
+
+// errorPage renders the static page for an error status, if there is one.
+func errorPage(m *file.Map, status int, message string) ([]byte, bool) {
+	code := strconv.Itoa(status)
+
+	if info, exists := m.Files[code+".html"]; exists {
+		if page, err := m.Content(info); err == nil && page != nil {
+			return page, true
+		}
+	}
+	if info, exists := m.Files[errorTemplatePath]; exists {
+		if page, err := m.Content(info); err == nil && page != nil {
+			page := strings.NewReplacer(
+				statusToken, code,
+				messageToken, html.EscapeString(message),
+			).Replace(string(page))
+			return []byte(page), true
+		}
+	}
+	return nil, false
+}
+
+// prefersJSON indicates whether the request Accept header gives JSON a
+// higher quality than HTML or, if they're equal, lists JSON first.
+func prefersJSON(r *http.Request) bool {
+	ranges := parseWeighted(r.Header.Get(header.Accept))
+	j, jPosition := mediaQuality(ranges, mime.Base(mime.JSON))
+	h, hPosition := mediaQuality(ranges, mime.Base(mime.HTML))
+
+	if j <= 0 {
+		return false
+	}
+	return j > h || (j == h && jPosition < hPosition)
+}
@@ -0,0 +1,83 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+func errorMap(t *testing.T) *file.Map {
+	m, err := file.InFS(fstest.MapFS{
+		"404.html":   {Data: []byte("<p>Not here</p>")},
+		"error.html": {Data: []byte("<p>{status} {message}</p>")},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, m.Read(false))
+	return m
+}
+
+func TestErrorPage(t *testing.T) {
+	m := errorMap(t)
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	r.Header.Set(header.Accept, "text/html,application/json")
+
+	w := httptest.NewRecorder()
+	writeError(w, r, m, http.StatusNotFound, "/missing does not exist")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, mime.HTML, w.Header().Get(content.Type))
+	assert.Equal(t, "<p>Not here</p>", w.Body.String())
+
+	w = httptest.NewRecorder()
+	writeError(w, r, m, http.StatusServiceUnavailable, "<script>")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "<p>503 &lt;script&gt;</p>", w.Body.String())
+}
+
+func TestErrorJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	r.Header.Set(header.Accept, "application/json, text/html")
+
+	w := httptest.NewRecorder()
+	writeError(w, r, errorMap(t), http.StatusNotFound, "/missing does not exist")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, mime.JSON, w.Header().Get(content.Type))
+	assert.JSONEq(t, `{"status":404,"message":"/missing does not exist"}`, w.Body.String())
+}
+
+func TestErrorText(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+
+	w := httptest.NewRecorder()
+	writeError(w, r, nil, http.StatusNotFound, "/<b> does not exist")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// plain text isn't escaped
+	assert.Equal(t, "/<b> does not exist\n", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get(content.Type))
+}
+
+func TestPrefersJSON(t *testing.T) {
+	for accept, expect := range map[string]bool{
+		"application/json":                  true,
+		"application/json, text/html":       true,
+		"text/html, application/json":       false,
+		"text/html;q=0.1, application/json": true,
+		"application/json;q=0.5, text/html": false,
+		"application/*, text/html;q=0.9":    true,
+		"*/*":                               false,
+		"text/*;q=0.5, */*":                 true,
+		"application/json;q=0, */*":         false,
+		"Application/JSON":                  true,
+		"":                                  false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		r.Header.Set(header.Accept, accept)
+		assert.Equal(t, expect, prefersJSON(r), accept)
+	}
+}
@@ -0,0 +1,178 @@
+package file
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var errArchiveClosed = errors.New("Archive has been closed")
+
+// archive is the zip file a Map, and the copies made as it changes, were
+// read from. It's closed once the Map is released and no streams from it
+// remain open.
+type archive struct {
+	sync.Mutex
+	file     io.Closer
+	streams  int
+	released bool
+	closed   bool
+}
+
+// stream is a file opened from an archive, which is told when it closes.
+type stream struct {
+	ReadSeekCloser
+	a    *archive
+	once sync.Once
+}
+
+// InZip returns all files inside a zip archive on disk. The path is relative
+// to the working directory unless absolute. The archive stays open for
+// reading until the Map is closed.
+func InZip(path string) (*Map, error) {
+	normalize()
+	if !filepath.IsAbs(path) {
+		path = wd + path
+	}
+	rc, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := InFS(&rc.Reader)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	m.closer = &archive{file: rc}
+
+	return m, nil
+}
+
+// Close releases the source of a Map read from a zip archive on disk. The
+// archive stays open until streams from it have been closed. Other Maps have
+// nothing to release.
+func (m *Map) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+	return m.closer.Close()
+}
+
+// Open returns a stream of file content from its source. Streams of a Map
+// read from a zip archive keep the archive open until they're closed.
+func (m *Map) Open(info *Info) (ReadSeekCloser, error) {
+	a, ok := m.closer.(*archive)
+	if !ok {
+		return info.Open()
+	}
+	if err := a.acquire(); err != nil {
+		return nil, err
+	}
+	f, err := info.Open()
+	if err != nil {
+		a.done()
+		return nil, err
+	}
+	return &stream{ReadSeekCloser: f, a: a}, nil
+}
+
+// read returns file content from its source, keeping a zip archive open
+// while it's read.
+func (m *Map) read(info *Info) ([]byte, error) {
+	a, ok := m.closer.(*archive)
+	if !ok {
+		return info.read()
+	}
+	if err := a.acquire(); err != nil {
+		return nil, err
+	}
+	defer a.done()
+
+	return info.read()
+}
+
+// Close closes the file and lets the archive close if it has been released
+// and this was the last stream.
+func (s *stream) Close() error {
+	err := s.ReadSeekCloser.Close()
+	s.once.Do(func() {
+		if doneErr := s.a.done(); err == nil {
+			err = doneErr
+		}
+	})
+	return err
+}
+
+// acquire counts a stream opened from the archive.
+func (a *archive) acquire() error {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.closed {
+		return errArchiveClosed
+	}
+	a.streams++
+	return nil
+}
+
+// done counts a closed stream, closing the archive if it was the last one
+// after release.
+func (a *archive) done() error {
+	a.Lock()
+	defer a.Unlock()
+
+	a.streams--
+	return a.closeIfUnused()
+}
+
+// Close releases the archive, closing it now if no streams are open or else
+// when the last one closes.
+func (a *archive) Close() error {
+	a.Lock()
+	defer a.Unlock()
+
+	a.released = true
+	return a.closeIfUnused()
+}
+
+// closeIfUnused closes a released archive without open streams. The lock
+// must be held.
+func (a *archive) closeIfUnused() error {
+	if !a.released || a.streams > 0 || a.closed {
+		return nil
+	}
+	a.closed = true
+	return a.file.Close()
+}
+
+// MonitorArchive calls reload whenever the zip archive at path is replaced
+// or modified. An archive that can't yet be opened, perhaps because it's
+// still being written, is checked again at the next interval.
+func MonitorArchive(path string, reload func()) {
+	normalize()
+	if !filepath.IsAbs(path) {
+		path = wd + path
+	}
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(pollInterval)
+
+	go func() {
+		for range ticker.C {
+			f, err := os.Stat(path)
+			if err != nil || (last != nil && f.ModTime().Equal(last.ModTime()) && f.Size() == last.Size()) {
+				continue
+			}
+			r, err := zip.OpenReader(path)
+			if err != nil {
+				continue
+			}
+			r.Close()
+			last = f
+			reload()
+		}
+	}()
+}
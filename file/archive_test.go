@@ -0,0 +1,74 @@
+package file_test
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	zw := zip.NewWriter(f)
+
+	for name, content := range files {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestInZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "static.zip")
+	writeZip(t, path, map[string]string{
+		"html/template.html": "<p>{name}</p>",
+		"js/app.js":          "var a = 1;",
+	})
+
+	m, err := file.InZip(path)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Read(true))
+	assert.Equal(t, "var a = 1;", string(m.Files["js/app.js"].Content))
+	assert.NoError(t, m.Close())
+}
+
+// TestArchiveStreams ensures a released archive stays open for streams
+// already reading from it.
+func TestArchiveStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "static.zip")
+	writeZip(t, path, map[string]string{"large.txt": "streamed content"})
+
+	m, err := file.InZip(path)
+	assert.NoError(t, err)
+	m.Budget = file.Budget{MaxFileBytes: 4}
+	assert.NoError(t, m.Read(false))
+
+	info := m.Files["large.txt"]
+	f, err := m.Open(info)
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.Close())
+	data, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "streamed content", string(data))
+	assert.NoError(t, f.Close())
+
+	// the archive closed with its last stream
+	_, err = m.Open(info)
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package file
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// releaseDelay is how long a replaced Map is left open for requests that
+// read it before it was replaced but haven't yet opened a stream. Streams
+// opened with Map.Open keep it open until they're closed.
+const releaseDelay = time.Minute
+
+// Cache holds the current Map snapshot. A Map is not modified once it has
+// been stored so readers never need a lock. Changes are instead applied to a
+// copy of the current Map which then replaces it.
+type Cache struct {
+	current atomic.Value
+	// writer serializes changes so none are lost between copy and swap.
+	writer sync.Mutex
+}
+
+// NewCache creates a Cache with an initial Map snapshot.
+func NewCache(m *Map) *Cache {
+	c := &Cache{}
+	c.current.Store(m)
+	return c
+}
+
+// Map returns the current snapshot. It must not be modified.
+func (c *Cache) Map() *Map {
+	return c.current.Load().(*Map)
+}
+
+// Swap replaces the current snapshot and returns the one replaced.
+func (c *Cache) Swap(m *Map) *Map {
+	c.writer.Lock()
+	defer c.writer.Unlock()
+
+	return c.current.Swap(m).(*Map)
+}
+
+// Replace swaps in a new snapshot then releases the one replaced after
+// releaseDelay. Its source is closed once any streams from it have also
+// been closed.
+func (c *Cache) Replace(m *Map) {
+	old := c.Swap(m)
+	if old != m && old.closer != m.closer {
+		time.AfterFunc(releaseDelay, func() { old.Close() })
+	}
+}
+
+// Update applies changes to a copy of the current snapshot then swaps the
+// copy in. Changes are published to subscribers after the swap.
+func (c *Cache) Update(fn func(m *Map) ([]*Change, error)) error {
+	c.writer.Lock()
+
+	next := c.Map().clone()
+	changes, err := fn(next)
+	if len(changes) > 0 {
+		c.current.Store(next)
+	}
+	c.writer.Unlock()
+
+	for _, change := range changes {
+		publish(change)
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+package file_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+func TestCacheSwap(t *testing.T) {
+	first := &file.Map{Files: map[string]*file.Info{"a": {Content: []byte("a")}}}
+	second := &file.Map{Files: map[string]*file.Info{"b": {Content: []byte("b")}}}
+
+	cache := file.NewCache(first)
+	assert.Equal(t, first, cache.Map())
+
+	old := cache.Swap(second)
+	assert.Equal(t, first, old)
+	assert.Equal(t, second, cache.Map())
+	assert.Contains(t, cache.Map().Files, "b")
+}
@@ -0,0 +1,47 @@
+package file
+
+import "sync"
+
+type (
+	// Change describes a Map entry the monitor created, updated or removed.
+	Change struct {
+		// Key is the Map key of the changed file.
+		Key string
+		// Info is the new file information or, for removals, the information
+		// that was removed.
+		Info *Info
+		Op   Op
+	}
+
+	// Op is the kind of change made to a Map entry.
+	Op int
+)
+
+const (
+	Created Op = iota
+	Updated
+	Removed
+)
+
+var (
+	subscribers []func(*Change)
+	subscribeMu sync.RWMutex
+)
+
+// Subscribe registers a function to be called with every change the monitor
+// detects.
+func Subscribe(fn func(*Change)) {
+	subscribeMu.Lock()
+	subscribers = append(subscribers, fn)
+	subscribeMu.Unlock()
+}
+
+// publish sends a change to all subscribers.
+func publish(c *Change) {
+	subscribeMu.RLock()
+	defer subscribeMu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(c)
+	}
+}
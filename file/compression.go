@@ -0,0 +1,79 @@
+package file
+
+import (
+	"compress/gzip"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Compression controls how file content is compressed when a Map is read.
+type Compression struct {
+	// Levels are compression levels keyed by content encoding, such as
+	// encoding.GZip. Encodings without a level use their default.
+	Levels map[string]int `json:"levels"`
+	// MinSize is the smallest content, in bytes, worth compressing.
+	MinSize int64 `json:"minSize"`
+	// MinRatio is the least ratio of original to compressed size for which
+	// a compressed variant is kept. A variant that isn't smaller than the
+	// original is never kept.
+	MinRatio float64 `json:"minRatio"`
+	// Workers is how many files are read and compressed at once. Zero uses
+	// one worker per CPU.
+	Workers int `json:"workers"`
+}
+
+// Stats summarizes the minification and compression of files when a Map was
+// read.
+type Stats struct {
+	// Files is the number of files with a compressed variant.
+	Files int
+	// Saved is the total bytes saved by compressed variants.
+	Saved int64
+	// Minified is the total bytes removed by minifiers.
+	Minified int64
+	// Elapsed is how long it took to read and compress all files.
+	Elapsed time.Duration
+}
+
+// level returns the configured compression level for a content encoding.
+func (c Compression) level(enc string) int {
+	if level, ok := c.Levels[enc]; ok {
+		return level
+	}
+	return gzip.DefaultCompression
+}
+
+// keep indicates whether a compressed variant is worth keeping.
+func (c Compression) keep(original, compressed int) bool {
+	if compressed >= original {
+		return false
+	}
+	return float64(original)/float64(compressed) >= c.MinRatio
+}
+
+// workers returns how many files to compress at once.
+func (c Compression) workers() int {
+	if c.Workers > 0 {
+		return c.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// CompressionStats summarizes minification and compression the last time the
+// Map was read.
+func (m *Map) CompressionStats() Stats {
+	return m.stats
+}
+
+// tally sums the bytes saved by compressed variants.
+func (m *Map) tally(elapsed time.Duration) Stats {
+	s := Stats{Elapsed: elapsed, Minified: atomic.LoadInt64(&m.minified)}
+	for _, info := range m.Files {
+		if info.Compressed != nil {
+			s.Files++
+			s.Saved += info.Size - info.Compressed.Size
+		}
+	}
+	return s
+}
@@ -3,8 +3,7 @@ package file
 import (
 	"archive/zip"
 	"errors"
-	"fmt"
-	"io/ioutil"
+	"io"
 	"strings"
 )
 
@@ -19,13 +18,13 @@ func HasZipData() bool {
 }
 
 // RegisterZip assigns zip data containing embedded files. This is usually
-// called by generated code.
+// called by code generated with cmd/embed.
 func RegisterZip(data string) {
 	zipData = data
 }
 
-// InZipFile returns all files inside the zip file data. Based on
-// https://github.com/rakyll/statik
+// InZipFile returns all files inside the zip file data. Content is not
+// unzipped until the Map is Read. Based on https://github.com/rakyll/statik
 func InZipFile() (*Map, error) {
 	if zipData == "" {
 		return nil, ErrNoZipData
@@ -34,23 +33,22 @@ func InZipFile() (*Map, error) {
 	if err != nil {
 		return nil, err
 	}
-	files := &Map{Files: make(map[string]*Info)}
-
-	for _, zipFile := range zipReader.File {
-		unzipped, err := unzip(zipFile)
-		if err != nil {
-			return nil, fmt.Errorf("error unzipping file %q: %s", zipFile.Name, err)
-		}
-		files.addZip(unzipped, zipFile)
-	}
-	return files, nil
+	return InFS(zipReader)
 }
 
-func unzip(zf *zip.File) ([]byte, error) {
-	rc, err := zf.Open()
+// openStored returns a reader for a zip entry stored without compression
+// that, unlike the zip file system, allows seeking.
+func openStored(zf *zip.File) (ReadSeekCloser, bool) {
+	if zf == nil || zf.Method != zip.Store {
+		return nil, false
+	}
+	raw, err := zf.OpenRaw()
 	if err != nil {
-		return nil, err
+		return nil, false
+	}
+	rs, ok := raw.(io.ReadSeeker)
+	if !ok {
+		return nil, false
 	}
-	defer rc.Close()
-	return ioutil.ReadAll(rc)
+	return nopCloser{rs}, true
 }
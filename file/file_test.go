@@ -2,7 +2,9 @@ package file_test
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/toba/coreweb/file"
 
@@ -77,8 +79,8 @@ func TestFileUpdate(t *testing.T) {
 
 	fileName := "update.txt"
 	testPath := wd + slash + "test" + slash + fileName
-	before := "before content"
-	after := "after content"
+	before := strings.Repeat("before content ", 10)
+	after := strings.Repeat("after content ", 10)
 
 	temp, err := os.Create(testPath)
 	assert.NoError(t, err)
@@ -102,10 +104,17 @@ func TestFileUpdate(t *testing.T) {
 	assert.NoError(t, err)
 
 	writeFile(t, temp, after)
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(testPath, later, later))
 
-	err = file.UpdateChangedFiles(m)
+	cache := file.NewCache(m)
+	err = file.UpdateChangedFiles(cache)
 	assert.NoError(t, err)
 
+	// the original snapshot is unchanged
+	assert.Equal(t, before, string(info.Content))
+
+	info = cache.Map().Files[fileName]
 	assert.Equal(t, after, string(info.Content))
 	assert.NotNil(t, info.Compressed)
 
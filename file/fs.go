@@ -0,0 +1,79 @@
+package file
+
+import (
+	"archive/zip"
+	"io/fs"
+	"strings"
+)
+
+// gzipExtension is added to the name of precompressed files.
+const gzipExtension = ".gz"
+
+// InFS retrieves all files in a file system such as embed.FS, zip.Reader or
+// os.DirFS. Content is not read until the Map is Read. A file with an added
+// .gz extension is used as the precompressed copy of the file it's named for
+// rather than becoming its own entry.
+func InFS(fsys fs.FS) (*Map, error) {
+	return inFS(fsys, true)
+}
+
+// inFS retrieves files at the root of a file system with the option to also
+// retrieve files from sub-directories.
+func inFS(fsys fs.FS, recursive bool) (*Map, error) {
+	m := &Map{Files: make(map[string]*Info), FS: fsys}
+	entries := zipEntries(fsys)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name != "." && !recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		f, err := d.Info()
+		if err != nil {
+			return err
+		}
+		info := m.newInfo(name, f)
+		info.zip = entries[name]
+		m.Files[name] = info
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.pairPrecompressed()
+
+	return m, nil
+}
+
+// pairPrecompressed removes GZipped copies of other files from the Map,
+// keeping them instead as the precompressed source of those files.
+func (m *Map) pairPrecompressed() {
+	for name, info := range m.Files {
+		if !strings.HasSuffix(name, gzipExtension) {
+			continue
+		}
+		if original, exists := m.Files[strings.TrimSuffix(name, gzipExtension)]; exists {
+			original.gzipped = info
+			delete(m.Files, name)
+		}
+	}
+}
+
+// zipEntries indexes zip files by name if the file system is a zip archive so
+// entries stored without compression can be streamed directly.
+func zipEntries(fsys fs.FS) map[string]*zip.File {
+	entries := make(map[string]*zip.File)
+
+	if r, ok := fsys.(*zip.Reader); ok {
+		for _, f := range r.File {
+			entries[f.Name] = f
+		}
+	}
+	return entries
+}
@@ -0,0 +1,88 @@
+package file_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+func TestInFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":   {Data: []byte("<html></html>")},
+		"js/app.js":    {Data: []byte(strings.Repeat("var a = 1;", 100))},
+		"css/site.css": {Data: []byte("body {}")},
+	}
+	m, err := file.InFS(fsys)
+	assert.NoError(t, err)
+	assert.Len(t, m.Files, 3)
+
+	err = m.Read(true)
+	assert.NoError(t, err)
+
+	info := m.Files["js/app.js"]
+	assert.NotNil(t, info)
+	assert.Equal(t, strings.Repeat("var a = 1;", 100), string(info.Content))
+	assert.Equal(t, mime.JavaScript, info.Header[content.Type])
+	assert.NotNil(t, info.Compressed)
+}
+
+func TestSniffWithoutExtension(t *testing.T) {
+	fsys := fstest.MapFS{
+		"home":  {Data: []byte("<!DOCTYPE html><html>" + strings.Repeat("<p></p>", 20) + "</html>")},
+		"blob.": {Data: []byte("<!DOCTYPE html>")},
+	}
+	m, err := file.InFS(fsys)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Read(true))
+
+	assert.Equal(t, mime.HTML, m.Files["home"].Header[content.Type])
+	assert.NotNil(t, m.Files["home"].Compressed)
+	assert.Equal(t, mime.Raw, m.Files["blob."].Header[content.Type])
+}
+
+// TestInZipFS ensures entries stored in a zip archive without compression
+// can be streamed.
+func TestInZipFS(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "video/clip.mp4", Method: zip.Store})
+	assert.NoError(t, err)
+	w.Write([]byte("stored content"))
+
+	w, err = zw.Create("readme.txt")
+	assert.NoError(t, err)
+	w.Write([]byte("deflated content"))
+	assert.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	m, err := file.InFS(zr)
+	assert.NoError(t, err)
+	assert.Len(t, m.Files, 2)
+
+	for name, expect := range map[string]string{
+		"video/clip.mp4": "stored content",
+		"readme.txt":     "deflated content",
+	} {
+		f, err := m.Files[name].Open()
+		assert.NoError(t, err)
+
+		_, err = f.Seek(0, 0)
+		assert.NoError(t, err)
+
+		data, err := ioutil.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, string(data))
+		f.Close()
+	}
+}
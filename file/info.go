@@ -1,8 +1,15 @@
 package file
 
 import (
+	"archive/zip"
 	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"compress/gzip"
@@ -15,39 +22,124 @@ import (
 )
 
 // Info contains information about a file and optionally its byte content and
-// a GZipped version.
+// a GZipped version. Content is nil for files not held in memory because of
+// the Map Budget.
 type Info struct {
 	Content    []byte
 	Header     map[string]string
 	Path       string
+	Size       int64
 	Compressed *Info
 	Modified   time.Time // only used if file watching is active (debug mode)
+	// Integrity is the Subresource Integrity digest of script and style sheet
+	// content, such as "sha384-<base64>".
+	Integrity string
+
+	// fsys is the file system content is read from.
+	fsys fs.FS
+	// zip is the archive entry for files read from a zip file system.
+	zip *zip.File
+	// gzipped is a precompressed copy of the file, named with an added .gz
+	// extension, used in place of compressing it.
+	gzipped *Info
 }
 
-// compressibleTypes lists the MIME types that can be GZipped.
-var compressibleTypes = [...]string{
-	mime.HTML,
-	mime.JavaScript,
-	mime.JSON,
-	mime.StyleSheet,
-	mime.SVG,
-	mime.Text,
-	mime.XML,
+// ReadSeekCloser is file content opened from its source.
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// nopCloser adds a Close method to readers that have no resources to release.
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// sniffLength is the most content considered when detecting MIME type.
+const sniffLength = 512
+
+// FromBytes creates File Info for content generated rather than read from a
+// file. The MIME type is inferred from the name and compatible content is
+// GZipped.
+func FromBytes(name string, data []byte, modified time.Time) *Info {
+	info := &Info{
+		Content: data,
+		Header: map[string]string{
+			content.Type:        mime.Infer(name),
+			content.Length:      strconv.Itoa(len(data)),
+			header.LastModified: modified.Format(time.RFC1123),
+		},
+		Path:     name,
+		Size:     int64(len(data)),
+		Modified: modified,
+	}
+	_ = info.Compress()
+	return info
 }
 
 // Replace creates new File Info where some content has been replaced.
 func (info *Info) Replace(token, name string) *Info {
+	return info.ReplaceAll(token, name)
+}
+
+// ReplaceAll creates new File Info where each token, followed by its
+// replacement in the list of pairs, has been replaced.
+func (info *Info) ReplaceAll(pairs ...string) *Info {
+	var buf bytes.Buffer
+	strings.NewReplacer(pairs...).WriteString(&buf, string(info.Content))
+
 	i := &Info{
-		Content:  bytes.Replace(info.Content, []byte(token), []byte(name), -1),
-		Header:   info.Header,
+		Content:  buf.Bytes(),
+		Header:   info.copyHeader(),
 		Path:     info.Path,
 		Modified: info.Modified,
 	}
+	i.Size = int64(len(i.Content))
+	i.Header[content.Length] = strconv.FormatInt(i.Size, 10)
 	_ = i.Compress()
 
 	return i
 }
 
+// Open returns a reader for the file content from its source file system.
+// Files that can't seek, such as compressed zip entries, are read into
+// memory for the duration of the read.
+func (info *Info) Open() (ReadSeekCloser, error) {
+	if rs, ok := openStored(info.zip); ok {
+		return rs, nil
+	}
+	if info.fsys == nil {
+		return os.Open(info.Path)
+	}
+	f, err := info.fsys.Open(info.Path)
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return struct {
+			io.ReadSeeker
+			io.Closer
+		}{rs, f}, nil
+	}
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(content)}, nil
+}
+
+// read returns all file content from its source file system.
+func (info *Info) read() ([]byte, error) {
+	if info.fsys == nil {
+		return ioutil.ReadFile(info.Path)
+	}
+	return fs.ReadFile(info.fsys, info.Path)
+}
+
 // copyHeader retrieves all header values as a string map.
 func (info *Info) copyHeader() map[string]string {
 	h := make(map[string]string)
@@ -62,37 +154,57 @@ func (info *Info) copyHeader() map[string]string {
 //
 // https://github.com/gin-contrib/gzip/blob/master/gzip.go
 func (info *Info) Compress() error {
-	if info.Compressible() {
-		var buffer bytes.Buffer
-		gz := gzip.NewWriter(&buffer)
-
-		if _, err := gz.Write(info.Content); err != nil {
-			return err
-		}
-		if err := gz.Close(); err != nil {
-			return err
-		}
+	return info.compress(info.Content, Compression{})
+}
 
-		zipped := buffer.Bytes()
-		head := info.copyHeader()
-		head[content.Encoding] = encoding.GZip
-		head[header.Vary] = accept.Encoding
-		head[content.Length] = strconv.FormatInt(int64(len(zipped)), 10)
-
-		info.Compressed = &Info{
-			Content:  zipped,
-			Path:     info.Path,
-			Header:   head,
-			Modified: info.Modified,
-		}
+// compress GZips content that may not be held by the Info itself. The
+// compressed variant is only kept if it meets the Compression thresholds.
+func (info *Info) compress(data []byte, c Compression) error {
+	if !info.compressible(data) || int64(len(data)) < c.MinSize {
+		return nil
+	}
+	var buffer bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buffer, c.level(encoding.GZip))
+	if err != nil {
+		return err
+	}
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if c.keep(len(data), buffer.Len()) {
+		info.setCompressed(buffer.Bytes())
 	}
 	return nil
 }
 
+// setCompressed assigns GZipped content with header values copied from the
+// uncompressed file.
+func (info *Info) setCompressed(zipped []byte) {
+	head := info.copyHeader()
+	head[content.Encoding] = encoding.GZip
+	head[header.Vary] = accept.Encoding
+	head[content.Length] = strconv.FormatInt(int64(len(zipped)), 10)
+
+	info.Compressed = &Info{
+		Content:  zipped,
+		Path:     info.Path,
+		Size:     int64(len(zipped)),
+		Header:   head,
+		Modified: info.Modified,
+	}
+}
+
 // Compressible indicates whether the file content can be compressed. Do not
 // re-compress and do not compress types that are already compact.
 func (info *Info) Compressible() bool {
-	if info.Content == nil || info.Compressed != nil {
+	return info.compressible(info.Content)
+}
+
+func (info *Info) compressible(data []byte) bool {
+	if data == nil || info.Compressed != nil {
 		return false
 	}
 
@@ -102,12 +214,26 @@ func (info *Info) Compressible() bool {
 		}
 	}
 
-	mimeType := info.Header[content.Type]
+	return mime.Compressible(info.Header[content.Type])
+}
 
-	for _, t := range compressibleTypes {
-		if mimeType == t {
-			return true
+// sniff replaces the generic MIME type of a file without an extension with
+// one detected from its content.
+func (info *Info) sniff(data []byte) {
+	if info.Header[content.Type] != mime.Raw || path.Ext(info.Path) != "" {
+		return
+	}
+	if data == nil {
+		// only the first bytes of a streamed file are needed
+		f, err := info.Open()
+		if err != nil {
+			return
 		}
+		defer f.Close()
+
+		head := make([]byte, sniffLength)
+		n, _ := io.ReadFull(f, head)
+		data = head[:n]
 	}
-	return false
+	info.Header[content.Type] = mime.Sniff(data)
 }
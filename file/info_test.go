@@ -1,7 +1,10 @@
 package file_test
 
 import (
+	"compress/gzip"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/toba/coreweb/encoding"
@@ -47,14 +50,43 @@ func TextInfoCompressible(t *testing.T) {
 // TestInfoCompress ensures compressible file is GZipped and has GZip header
 // but that parent info retains standard header fields.
 func TestInfoCompress(t *testing.T) {
-	m, err := file.InFolder(folder, true)
+	m, err := file.InFS(fstest.MapFS{
+		"file1a.txt": {Data: []byte(strings.Repeat("compressible ", 20))},
+	})
 	assert.NoError(t, err)
 	err = m.Read(true)
 	assert.NoError(t, err)
 
-	info := m.Files["folder1"+slash+"file1a.txt"]
+	info := m.Files["file1a.txt"]
 
 	assert.NotNil(t, info.Compressed)
 	assert.Equal(t, encoding.GZip, info.Compressed.Header[content.Encoding])
 	assert.NotEqual(t, encoding.GZip, info.Header[content.Encoding])
 }
+
+// TestCompressionThresholds ensures compressed variants are only kept when
+// the content is large enough and compresses well enough.
+func TestCompressionThresholds(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tiny.txt":   {Data: []byte("tiny")},
+		"repeat.txt": {Data: []byte(strings.Repeat("a", 1000))},
+		"mixed.txt":  {Data: []byte("The quick brown fox jumps over the lazy dog")},
+	}
+	m, err := file.InFS(fsys)
+	assert.NoError(t, err)
+	m.Compression = file.Compression{
+		Levels:   map[string]int{encoding.GZip: gzip.BestCompression},
+		MinSize:  10,
+		MinRatio: 2,
+		Workers:  2,
+	}
+	assert.NoError(t, m.Read(true))
+
+	assert.Nil(t, m.Files["tiny.txt"].Compressed)
+	assert.Nil(t, m.Files["mixed.txt"].Compressed)
+	assert.NotNil(t, m.Files["repeat.txt"].Compressed)
+
+	stats := m.CompressionStats()
+	assert.Equal(t, 1, stats.Files)
+	assert.Equal(t, 1000-m.Files["repeat.txt"].Compressed.Size, stats.Saved)
+}
@@ -0,0 +1,50 @@
+package file
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+// integrityPrefix identifies the hash algorithm of a Subresource Integrity
+// digest.
+//
+// https://developer.mozilla.org/en-US/docs/Web/Security/Subresource_Integrity
+const integrityPrefix = "sha384-"
+
+// hasIntegrity indicates whether a file is a script or style sheet that
+// pages can load with an integrity attribute.
+func (info *Info) hasIntegrity() bool {
+	t := info.Header[content.Type]
+	return strings.HasPrefix(t, "text/javascript") || strings.HasPrefix(t, mime.StyleSheet)
+}
+
+// setIntegrity computes the Subresource Integrity digest of script and style
+// sheet content. Content that isn't held in memory is streamed from its
+// source.
+func (info *Info) setIntegrity(data []byte) error {
+	if !info.hasIntegrity() {
+		return nil
+	}
+	hash := sha512.New384()
+
+	if data != nil {
+		hash.Write(data)
+	} else {
+		f, err := info.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err = io.Copy(hash, f); err != nil {
+			return err
+		}
+	}
+	info.Integrity = integrityPrefix + base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	return nil
+}
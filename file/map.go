@@ -1,62 +1,364 @@
 package file
 
 import (
-	"archive/zip"
-	"io/ioutil"
-	"os"
+	"bytes"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/toba/coreweb/header/content"
 )
 
-// Map file path to information about the file.
-type Map struct {
-	sync.RWMutex
-	Files map[string]*Info
-}
+type (
+	// Map file path to information about the file. Once added to a Cache
+	// the Map and the Info it contains must be treated as immutable.
+	Map struct {
+		Files map[string]*Info
+		// FS is the file system files were read from.
+		FS fs.FS
+		// Root is the operating system folder files were read from, if any,
+		// allowing them to be monitored for changes.
+		Root string
+		// Recursive indicates whether sub-folders of Root were read.
+		Recursive bool
+		// Budget limits the file content held in memory. It must be set
+		// before the Map is Read.
+		Budget Budget
+		// Compression controls how content is GZipped. It must be set before
+		// the Map is Read.
+		Compression Compression
+		// Minify transforms content with the Minifier registered for its MIME
+		// type. It must be set before the Map is Read.
+		Minify bool
+		// Version identifies the bundle files were read from, if any.
+		Version string
+
+		// sources are files not served directly but rendered into derived
+		// entries, keyed by their path within FS.
+		sources map[string]*Info
+		derived map[string]map[string]Render
+		// memory holds recently used content when the Budget is limited.
+		memory *memory
+		// closer releases the archive files were read from.
+		closer io.Closer
+		// stats summarize compression when the Map was read.
+		stats Stats
+		// minified counts bytes removed by minifiers while reading.
+		minified int64
+	}
+
+	// Render generates a Map entry from the content of a source file. The Map
+	// allows the entry to refer to other files, such as by their Integrity.
+	Render func(m *Map, source *Info) *Info
+)
 
-// Read updates all Content bytes in the Map and optionally GZips them.
+// Read updates all Content bytes in the Map and optionally GZips them. If
+// the Map Budget is limited then content is instead held in memory only
+// until the budget is reached and files too large to hold in memory are
+// neither read nor GZipped. Files are read by as many workers as the Map
+// Compression allows.
 func (m *Map) Read(gzip bool) error {
 	normalize()
-	for _, info := range m.Files {
-		if info.Content == nil {
-			content, err := ioutil.ReadFile(info.Path)
-			if err != nil {
-				return err
+	if !m.Budget.Unlimited() && m.memory == nil {
+		m.memory = newMemory(m.Budget)
+	}
+	atomic.StoreInt64(&m.minified, 0)
+
+	var (
+		start = time.Now()
+		work  = make(chan *Info)
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for i := 0; i < m.Compression.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range work {
+				if err := m.load(info, gzip); err != nil {
+					once.Do(func() { first = err })
+				}
 			}
-			info.Content = content
+		}()
+	}
+	for _, info := range m.Files {
+		work <- info
+	}
+	close(work)
+	wg.Wait()
+
+	m.stats = m.tally(time.Since(start))
+	return first
+}
+
+// load reads file content into memory, subject to the Budget, and
+// optionally GZips it.
+func (m *Map) load(info *Info, gzip bool) error {
+	data := info.Content
+
+	if data == nil {
+		if m.Budget.Streams(info.Size) {
+			info.sniff(nil)
+			return info.setIntegrity(nil)
+		}
+		content, err := info.read()
+		if err != nil {
+			return err
 		}
+		data = content
+	}
+	info.sniff(data)
 
-		if gzip {
-			err := info.Compress()
-			if err != nil {
-				return err
-			}
+	if m.Minify {
+		small, err := m.minify(info, data)
+		if err != nil {
+			return err
+		}
+		if saved := int64(len(data) - len(small)); saved > 0 {
+			atomic.AddInt64(&m.minified, saved)
+			info.Size = int64(len(small))
+			info.Header[content.Length] = strconv.Itoa(len(small))
+			data = small
 		}
 	}
+
+	if err := info.setIntegrity(data); err != nil {
+		return err
+	}
+
+	if gzip && info.gzipped != nil {
+		zipped, err := info.gzipped.read()
+		if err != nil {
+			return err
+		}
+		info.setCompressed(zipped)
+	} else if gzip {
+		if err := info.compress(data, m.Compression); err != nil {
+			return err
+		}
+	}
+	if m.memory != nil && info.Compressed != nil && !m.memory.pin(info, info.Compressed.Size) {
+		// the budget has no room for another GZipped copy so the file is
+		// sent uncompressed
+		info.Compressed = nil
+	}
+
+	if m.memory == nil {
+		info.Content = data
+	} else if info.Content == nil {
+		m.memory.put(info, data)
+	}
 	return nil
 }
 
-// add os.FileInfo to the Map.
-func (m *Map) add(filePath, rootFolder string, info os.FileInfo) {
-	m.Files[makeRelative(filePath, rootFolder)] = &Info{
-		Header:   makeHeader(info),
-		Path:     filePath,
-		Modified: info.ModTime(),
+// Content returns the bytes for a file, reading them from their source if
+// they aren't held in memory. Content is minified again each time it's read
+// from its source. A nil result without an error means the file is too large
+// to hold in memory and should be streamed with Open.
+func (m *Map) Content(info *Info) ([]byte, error) {
+	if info.Content != nil || m.memory == nil {
+		return info.Content, nil
+	}
+	if content, ok := m.memory.get(info); ok {
+		return content, nil
+	}
+	if m.Budget.Streams(info.Size) {
+		return nil, nil
+	}
+	content, err := m.read(info)
+	if err == nil && m.Minify {
+		// content was minified when first read
+		content, err = m.minify(info, content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.memory.put(info, content)
+
+	return content, nil
+}
+
+// MemoryUsed returns the bytes of file content and GZipped copies held in
+// memory, not counting derived content, if the Budget is limited.
+func (m *Map) MemoryUsed() int64 {
+	if m.memory == nil {
+		return 0
+	}
+	return m.memory.size()
+}
+
+// Purge drops file content held in memory, if the Budget is limited, so it's
+// read again from its source when next requested.
+func (m *Map) Purge() {
+	if m.memory != nil {
+		m.memory.purge()
 	}
 }
 
-// addAll adds all members of one file Map to this one.
-func (m *Map) addAll(other *Map) {
-	for k, v := range other.Files {
-		m.Files[k] = v
+// release returns the budget held by a file's GZipped copy once it's no
+// longer in the Map.
+func (m *Map) release(info *Info) {
+	if m.memory != nil {
+		m.memory.unpin(info)
 	}
 }
 
-// addFromZip adds zip file information to the Map.
-func (m *Map) addZip(content []byte, f *zip.File) {
-	m.Files[f.Name] = &Info{
-		Header:   makeHeader(f.FileInfo()),
-		Content:  content,
-		Path:     f.Name,
+// Derive adds an entry generated from the content of a source file, such as
+// a module page rendered from a template. The source itself is not served
+// but the entry is regenerated whenever the monitor sees the source change.
+// It must be called before the Map is added to a Cache.
+func (m *Map) Derive(key string, source *Info, render Render) {
+	if source.Content == nil {
+		// rendered entries are always held in memory so their source is too
+		content, err := source.read()
+		if err != nil {
+			log.Printf("Unable to read %s: %v", source.Path, err)
+		}
+		resident := *source
+		resident.Content = content
+		source = &resident
+	}
+	if m.sources == nil {
+		m.sources = make(map[string]*Info)
+		m.derived = make(map[string]map[string]Render)
+	}
+	if _, exists := m.derived[source.Path]; !exists {
+		m.derived[source.Path] = make(map[string]Render)
+	}
+	m.sources[source.Path] = source
+	m.derived[source.Path][key] = render
+	m.Files[key] = render(m, source)
+}
+
+// clone copies the Map so it can be changed without affecting readers of the
+// original. Info values are shared since they're never modified.
+func (m *Map) clone() *Map {
+	c := &Map{
+		Files:       make(map[string]*Info, len(m.Files)),
+		FS:          m.FS,
+		Root:        m.Root,
+		Recursive:   m.Recursive,
+		Budget:      m.Budget,
+		Version:     m.Version,
+		Compression: m.Compression,
+		Minify:      m.Minify,
+		stats:       m.stats,
+		sources:     make(map[string]*Info, len(m.sources)),
+		derived:     m.derived,
+		memory:      m.memory,
+		closer:      m.closer,
+	}
+	for k, v := range m.Files {
+		c.Files[k] = v
+	}
+	for k, v := range m.sources {
+		c.sources[k] = v
+	}
+	return c
+}
+
+// key converts an operating system path within Root to a Map key, which is
+// also the path within FS.
+func (m *Map) key(path string) string {
+	return filepath.ToSlash(makeRelative(path, m.Root))
+}
+
+// newInfo creates information for a file within FS. Content is not read.
+func (m *Map) newInfo(name string, f fs.FileInfo) *Info {
+	return &Info{
+		Header:   makeHeader(f),
+		Path:     name,
+		Size:     f.Size(),
 		Modified: f.ModTime(),
+		fsys:     m.FS,
+	}
+}
+
+// update reads a created or modified file into the Map, regenerating any
+// entries derived from it, and returns the resulting changes.
+func (m *Map) update(name string, f fs.FileInfo) ([]*Change, error) {
+	info := m.newInfo(name, f)
+	renders, isSource := m.derived[name]
+
+	if isSource {
+		content, err := info.read()
+		if err == nil && m.Minify {
+			content, err = m.minify(info, content)
+		}
+		if err != nil {
+			return nil, err
+		}
+		info.Content = content
+		m.sources[name] = info
+		changes := []*Change{}
+
+		for key, render := range renders {
+			m.Files[key] = render(m, info)
+			changes = append(changes, &Change{Key: key, Info: m.Files[key], Op: Updated})
+		}
+		return changes, nil
+	}
+
+	if err := m.load(info, true); err != nil {
+		return nil, err
+	}
+	op := Created
+
+	if old, exists := m.Files[name]; exists {
+		op = Updated
+		m.release(old)
+	}
+	m.Files[name] = info
+	changes := []*Change{{Key: name, Info: info, Op: op}}
+
+	// derived entries may refer to the file, such as by its integrity
+	return append(changes, m.rerender()...), nil
+}
+
+// rerender regenerates every derived entry, as when a file they may refer
+// to has changed, and returns changes for those with different content.
+func (m *Map) rerender() []*Change {
+	changes := []*Change{}
+
+	for name, renders := range m.derived {
+		source, exists := m.sources[name]
+		if !exists {
+			continue
+		}
+		for key, render := range renders {
+			info := render(m, source)
+			if old, exists := m.Files[key]; exists && bytes.Equal(old.Content, info.Content) {
+				continue
+			}
+			m.Files[key] = info
+			changes = append(changes, &Change{Key: key, Info: info, Op: Updated})
+		}
+	}
+	return changes
+}
+
+// remove deletes the entry for a file, or for all files within a folder,
+// and returns the resulting changes. Entries derived from a removed source
+// keep their last rendered content.
+func (m *Map) remove(name string) []*Change {
+	changes := []*Change{}
+
+	for k, info := range m.Files {
+		if k == name || strings.HasPrefix(k, name+"/") {
+			if _, isDerived := m.derived[info.Path]; isDerived {
+				continue
+			}
+			delete(m.Files, k)
+			m.release(info)
+			changes = append(changes, &Change{Key: k, Info: info, Op: Removed})
+		}
 	}
+	return changes
 }
@@ -0,0 +1,162 @@
+package file
+
+import (
+	"container/list"
+	"sync"
+)
+
+type (
+	// Budget limits how much file content is held in memory.
+	Budget struct {
+		// MaxBytes is the total size of file content, including GZipped
+		// copies, that may be held in memory. Zero means all content is held
+		// in memory.
+		MaxBytes int64
+		// MaxFileBytes is the size of the largest file that may be held in
+		// memory. Larger files are always streamed from their source. Zero
+		// means there is no per-file limit.
+		MaxFileBytes int64
+	}
+
+	// memory is a least-recently-used cache of file content kept within a
+	// Budget. It is shared by all snapshots of a Map. GZipped copies can't be
+	// read again so they're pinned rather than evicted, leaving less of the
+	// budget for content.
+	memory struct {
+		sync.Mutex
+		budget  Budget
+		used    int64
+		order   *list.List
+		entries map[*Info]*list.Element
+		pinned  map[*Info]int64
+		// held is the total size of pinned copies.
+		held int64
+	}
+
+	// memoryEntry is file content held in memory.
+	memoryEntry struct {
+		info    *Info
+		content []byte
+	}
+)
+
+// Unlimited indicates whether all content is held in memory.
+func (b Budget) Unlimited() bool {
+	return b.MaxBytes <= 0
+}
+
+// Streams indicates whether a file of the given size is too large to be held
+// in memory.
+func (b Budget) Streams(size int64) bool {
+	return (b.MaxFileBytes > 0 && size > b.MaxFileBytes) ||
+		(b.MaxBytes > 0 && size > b.MaxBytes)
+}
+
+func newMemory(b Budget) *memory {
+	return &memory{
+		budget:  b,
+		order:   list.New(),
+		entries: make(map[*Info]*list.Element),
+		pinned:  make(map[*Info]int64),
+	}
+}
+
+// get returns content held for a file and marks it most recently used.
+func (mem *memory) get(info *Info) ([]byte, bool) {
+	mem.Lock()
+	defer mem.Unlock()
+
+	if e, ok := mem.entries[info]; ok {
+		mem.order.MoveToFront(e)
+		return e.Value.(*memoryEntry).content, true
+	}
+	return nil, false
+}
+
+// put holds content for a file, evicting the least recently used content as
+// needed to stay within budget.
+func (mem *memory) put(info *Info, content []byte) {
+	size := int64(len(content))
+
+	if mem.budget.Streams(size) {
+		return
+	}
+
+	mem.Lock()
+	defer mem.Unlock()
+
+	if e, ok := mem.entries[info]; ok {
+		mem.order.MoveToFront(e)
+		return
+	}
+	if mem.held+size > mem.budget.MaxBytes {
+		// pinned copies leave no room
+		return
+	}
+	mem.evict(size)
+	mem.entries[info] = mem.order.PushFront(&memoryEntry{info: info, content: content})
+	mem.used += size
+}
+
+// evict drops the least recently used content until there's room for size
+// more bytes. The lock must be held.
+func (mem *memory) evict(size int64) {
+	for mem.used+mem.held+size > mem.budget.MaxBytes && mem.order.Len() > 0 {
+		oldest := mem.order.Back()
+		entry := oldest.Value.(*memoryEntry)
+		mem.order.Remove(oldest)
+		delete(mem.entries, entry.info)
+		mem.used -= int64(len(entry.content))
+	}
+}
+
+// pin counts the GZipped copy of a file against the budget, evicting content
+// to make room. False means the budget can't hold the copy so it shouldn't
+// be kept.
+func (mem *memory) pin(info *Info, size int64) bool {
+	mem.Lock()
+	defer mem.Unlock()
+
+	if _, ok := mem.pinned[info]; ok {
+		return true
+	}
+	if mem.budget.Streams(size) || mem.held+size > mem.budget.MaxBytes {
+		return false
+	}
+	mem.pinned[info] = size
+	mem.held += size
+	mem.evict(0)
+
+	return true
+}
+
+// unpin releases the budget held by a file's GZipped copy once the file is
+// updated or removed.
+func (mem *memory) unpin(info *Info) {
+	mem.Lock()
+	defer mem.Unlock()
+
+	if size, ok := mem.pinned[info]; ok {
+		delete(mem.pinned, info)
+		mem.held -= size
+	}
+}
+
+// size returns the number of bytes held in memory, including pinned copies.
+func (mem *memory) size() int64 {
+	mem.Lock()
+	defer mem.Unlock()
+
+	return mem.used + mem.held
+}
+
+// purge drops all content held in memory. Pinned copies are still in use so
+// remain.
+func (mem *memory) purge() {
+	mem.Lock()
+	defer mem.Unlock()
+
+	mem.order.Init()
+	mem.entries = make(map[*Info]*list.Element)
+	mem.used = 0
+}
@@ -0,0 +1,113 @@
+package file_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+// TestMemoryBudget ensures no more content than budgeted is held in memory
+// while all content remains available.
+func TestMemoryBudget(t *testing.T) {
+	m, err := file.InFolder(folder, true)
+	assert.NoError(t, err)
+
+	m.Budget = file.Budget{MaxBytes: 12}
+	err = m.Read(false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), m.MemoryUsed())
+
+	for _, info := range m.Files {
+		assert.Nil(t, info.Content)
+
+		content, err := m.Content(info)
+		assert.NoError(t, err)
+		assert.Len(t, content, 6)
+		assert.True(t, m.MemoryUsed() <= 12)
+	}
+}
+
+// TestMemoryPurge ensures purged content is read again when requested.
+func TestMemoryPurge(t *testing.T) {
+	m, err := file.InFolder(folder, true)
+	assert.NoError(t, err)
+
+	m.Budget = file.Budget{MaxBytes: 100}
+	err = m.Read(false)
+	assert.NoError(t, err)
+	assert.True(t, m.MemoryUsed() > 0)
+
+	m.Purge()
+	assert.Equal(t, int64(0), m.MemoryUsed())
+
+	for _, info := range m.Files {
+		content, err := m.Content(info)
+		assert.NoError(t, err)
+		assert.Len(t, content, 6)
+	}
+	assert.True(t, m.MemoryUsed() > 0)
+}
+
+// TestMemoryStream ensures files larger than the per-file limit are streamed.
+func TestMemoryStream(t *testing.T) {
+	m, err := file.InFolder(folder, true)
+	assert.NoError(t, err)
+
+	m.Budget = file.Budget{MaxBytes: 100, MaxFileBytes: 5}
+	err = m.Read(true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), m.MemoryUsed())
+
+	info := m.Files["file0a.txt"]
+	assert.Nil(t, info.Compressed)
+
+	content, err := m.Content(info)
+	assert.NoError(t, err)
+	assert.Nil(t, content)
+
+	f, err := info.Open()
+	assert.NoError(t, err)
+	defer f.Close()
+
+	streamed, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "file0a", string(streamed))
+}
+
+// TestMemoryCompressed ensures GZipped copies count against the budget and
+// files are sent uncompressed once it can hold no more.
+func TestMemoryCompressed(t *testing.T) {
+	files := fstest.MapFS{}
+	for i := 0; i < 4; i++ {
+		text := ""
+		for j := 0; len(text) < 4000; j++ {
+			hash := sha256.Sum256([]byte(strconv.Itoa(i*1000 + j)))
+			text += hex.EncodeToString(hash[:])
+		}
+		files["file"+strconv.Itoa(i)+".txt"] = &fstest.MapFile{Data: []byte(text)}
+	}
+	m, err := file.InFS(files)
+	assert.NoError(t, err)
+
+	m.Budget = file.Budget{MaxBytes: 5000}
+	assert.NoError(t, m.Read(true))
+	assert.True(t, m.MemoryUsed() <= 5000)
+
+	compressed := 0
+	for _, info := range m.Files {
+		if info.Compressed != nil {
+			compressed++
+		}
+		content, err := m.Content(info)
+		assert.NoError(t, err)
+		assert.Len(t, content, int(info.Size))
+		assert.True(t, m.MemoryUsed() <= 5000)
+	}
+	assert.Equal(t, 2, compressed)
+}
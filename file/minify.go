@@ -0,0 +1,92 @@
+package file
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+	"github.com/toba/coreweb/minify"
+)
+
+type (
+	// Minifier transforms file content to a smaller equivalent.
+	Minifier interface {
+		Minify(data []byte) ([]byte, error)
+	}
+
+	// MinifierFunc adapts a function to the Minifier interface.
+	MinifierFunc func(data []byte) ([]byte, error)
+)
+
+// Minify calls the function.
+func (fn MinifierFunc) Minify(data []byte) ([]byte, error) {
+	return fn(data)
+}
+
+// infallible adapts a transform that can't fail to the Minifier interface.
+func infallible(fn func([]byte) []byte) Minifier {
+	return MinifierFunc(func(data []byte) ([]byte, error) {
+		return fn(data), nil
+	})
+}
+
+// sourceMappingURL names the source map of a script or style sheet.
+var sourceMappingURL = []byte("sourceMappingURL=")
+
+var (
+	// minifiers are keyed by MIME type without parameters.
+	minifiers = map[string]Minifier{
+		"text/html":       infallible(minify.HTML),
+		"text/css":        infallible(minify.CSS),
+		"text/javascript": infallible(minify.JS),
+		"image/svg+xml":   infallible(minify.SVG),
+	}
+	minifierMu sync.RWMutex
+)
+
+// RegisterMinifier sets the Minifier for a MIME type, replacing any built-in
+// one. A nil Minifier leaves content of that type unchanged.
+func RegisterMinifier(mimeType string, m Minifier) {
+	minifierMu.Lock()
+	defer minifierMu.Unlock()
+
+	minifiers[mime.Base(mimeType)] = m
+}
+
+// minifier returns the Minifier for a file, if it should be minified. Files
+// already minified, like app.min.js, or precompressed are left unchanged.
+func (info *Info) minifier() Minifier {
+	if strings.Contains(info.Path, ".min.") || info.gzipped != nil {
+		return nil
+	}
+	minifierMu.RLock()
+	defer minifierMu.RUnlock()
+
+	return minifiers[mime.Base(info.Header[content.Type])]
+}
+
+// minify returns content transformed by the Minifier for its MIME type. The
+// content is returned unchanged if the transform doesn't make it smaller or
+// the file has a source map, whose positions minifying would invalidate.
+func (m *Map) minify(info *Info, data []byte) ([]byte, error) {
+	fn := info.minifier()
+	if fn == nil || m.hasSourceMap(info, data) {
+		return data, nil
+	}
+	small, err := fn.Minify(data)
+	if err != nil || len(small) >= len(data) {
+		return data, err
+	}
+	return small, nil
+}
+
+// hasSourceMap indicates whether a file refers to a source map or has one
+// beside it with a .map extension.
+func (m *Map) hasSourceMap(info *Info, data []byte) bool {
+	if _, exists := m.Files[info.Path+".map"]; exists {
+		return true
+	}
+	return bytes.Contains(data, sourceMappingURL)
+}
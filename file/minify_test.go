@@ -0,0 +1,84 @@
+package file_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header/content"
+)
+
+func TestMinify(t *testing.T) {
+	script := "// comment\nvar a = 1;\n"
+	m, err := file.InFS(fstest.MapFS{
+		"js/app.js":     {Data: []byte(script)},
+		"js/lib.min.js": {Data: []byte(script)},
+		"data.csv":      {Data: []byte("a,  b\n")},
+	})
+	assert.NoError(t, err)
+
+	file.RegisterMinifier("text/csv", file.MinifierFunc(func(data []byte) ([]byte, error) {
+		return bytes.Replace(data, []byte("  "), nil, -1), nil
+	}))
+	defer file.RegisterMinifier("text/csv", nil)
+
+	m.Minify = true
+	assert.NoError(t, m.Read(true))
+
+	app := m.Files["js/app.js"]
+	assert.Equal(t, "var a=1;", string(app.Content))
+	assert.Equal(t, "8", app.Header[content.Length])
+	assert.Equal(t, int64(8), app.Size)
+
+	assert.Equal(t, script, string(m.Files["js/lib.min.js"].Content))
+	assert.Equal(t, "a,b\n", string(m.Files["data.csv"].Content))
+	assert.Equal(t, int64(len(script)-8+2), m.CompressionStats().Minified)
+}
+
+// TestMinifySourceMap ensures scripts and style sheets with source maps are
+// left unchanged so the mapped positions stay correct.
+func TestMinifySourceMap(t *testing.T) {
+	script := "// comment\nvar a = 1;\n"
+	commented := script + "//# sourceMappingURL=commented.js.map\n"
+	style := "a {\n  color: red;\n}\n/*# sourceMappingURL=/css/app.css.map */\n"
+
+	m, err := file.InFS(fstest.MapFS{
+		"js/plain.js":      {Data: []byte(script)},
+		"js/beside.js":     {Data: []byte(script)},
+		"js/beside.js.map": {Data: []byte(`{"version": 3}`)},
+		"js/commented.js":  {Data: []byte(commented)},
+		"css/app.css":      {Data: []byte(style)},
+	})
+	assert.NoError(t, err)
+	m.Minify = true
+	assert.NoError(t, m.Read(false))
+
+	assert.Equal(t, "var a=1;", string(m.Files["js/plain.js"].Content))
+	assert.Equal(t, script, string(m.Files["js/beside.js"].Content))
+	assert.Equal(t, commented, string(m.Files["js/commented.js"].Content))
+	assert.Equal(t, style, string(m.Files["css/app.css"].Content))
+}
+
+// TestMinifyBudget ensures content dropped from memory is minified again
+// when re-read.
+func TestMinifyBudget(t *testing.T) {
+	m, err := file.InFS(fstest.MapFS{
+		"a.js": {Data: []byte("var a = 1; // first")},
+		"b.js": {Data: []byte("var b = 2; // second")},
+		"c.js": {Data: []byte("var c = 3; // third")},
+	})
+	assert.NoError(t, err)
+	m.Minify = true
+	m.Budget = file.Budget{MaxBytes: 20}
+	assert.NoError(t, m.Read(false))
+
+	assert.True(t, m.MemoryUsed() <= 20)
+
+	for name, expect := range map[string]string{"a.js": "var a=1;", "b.js": "var b=2;", "c.js": "var c=3;"} {
+		data, err := m.Content(m.Files[name])
+		assert.NoError(t, err)
+		assert.Equal(t, expect, string(data))
+	}
+}
@@ -0,0 +1,233 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pollInterval is how often files are checked for changes when operating
+// system notifications are unavailable.
+const pollInterval = 3 * time.Second
+
+// Monitor keeps the cached Map current as files in its Root folder are
+// created, updated, renamed or removed, publishing each change to
+// subscribers. Operating system notifications are used where available with
+// a fallback to polling.
+//
+// https://github.com/fsnotify/fsnotify
+func Monitor(c *Cache) {
+	m := c.Map()
+
+	if m.Root == "" {
+		log.Print("Only files read from a folder can be monitored")
+		return
+	}
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watch(w, m.Root, m.Recursive)
+	}
+	if err != nil {
+		log.Printf("Polling for file changes: %v", err)
+		if w != nil {
+			w.Close()
+		}
+		poll(c)
+		return
+	}
+	go notify(w, c)
+}
+
+// watch adds a folder and, if recursive, all of its sub-folders to the
+// watcher.
+func watch(w *fsnotify.Watcher, folder string, recursive bool) error {
+	if !recursive {
+		return w.Add(folder)
+	}
+	return filepath.Walk(folder, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			return w.Add(path)
+		}
+		return nil
+	})
+}
+
+// notify is an event loop that applies watcher events to the cached Map.
+func notify(w *fsnotify.Watcher, c *Cache) {
+	defer w.Close()
+
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			err := c.Update(func(m *Map) ([]*Change, error) {
+				return apply(w, m, e)
+			})
+			if err != nil {
+				log.Printf("Failed to apply %s: %v", e, err)
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("File watcher error: %v", err)
+		}
+	}
+}
+
+// apply updates the Map for a single watcher event. A renamed file is
+// reported as the removal of its old name and creation of its new one.
+func apply(w *fsnotify.Watcher, m *Map, e fsnotify.Event) ([]*Change, error) {
+	if m.Root == "" {
+		// the folder snapshot has been replaced, as by an uploaded bundle
+		return nil, nil
+	}
+	name := m.key(e.Name)
+
+	if e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename) {
+		return m.remove(name), nil
+	}
+	if !e.Has(fsnotify.Create) && !e.Has(fsnotify.Write) {
+		return nil, nil
+	}
+
+	f, err := os.Stat(e.Name)
+	if err != nil {
+		// removed before it could be read
+		return m.remove(name), nil
+	}
+	if !f.IsDir() {
+		return m.update(name, f)
+	}
+	if !m.Recursive {
+		return nil, nil
+	}
+	// a folder moved into place may already contain files
+	if err = watch(w, e.Name, true); err != nil {
+		return nil, err
+	}
+	changes := []*Change{}
+	err = walk(e.Name, true, func(path string, f os.FileInfo) error {
+		updated, err := m.update(m.key(path), f)
+		changes = append(changes, updated...)
+		return err
+	})
+	return changes, err
+}
+
+// poll checks for changed files at a regular interval.
+func poll(c *Cache) {
+	ticker := time.NewTicker(pollInterval)
+	go func() {
+		for range ticker.C {
+			if err := UpdateChangedFiles(c); err != nil {
+				log.Print(err)
+			}
+		}
+	}()
+}
+
+// walk calls fn for every file in a folder and, if recursive, its
+// sub-folders.
+func walk(folder string, recursive bool, fn func(path string, f os.FileInfo) error) error {
+	if recursive {
+		return filepath.Walk(folder, func(path string, f os.FileInfo, err error) error {
+			if err != nil || f.IsDir() {
+				return err
+			}
+			return fn(path, f)
+		})
+	}
+	info, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	for _, f := range info {
+		if !f.IsDir() {
+			if err = fn(folder+slash+f.Name(), f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateChangedFiles compares the cached Map to its Root folder, adding new
+// files, updating those with a newer modified time and removing those that
+// no longer exist. Changes are published to subscribers.
+func UpdateChangedFiles(c *Cache) error {
+	current := c.Map()
+
+	if current.Root == "" {
+		return nil
+	}
+	// found files keyed by their path within the Map file system
+	found := make(map[string]os.FileInfo)
+	err := walk(current.Root, current.Recursive, func(path string, f os.FileInfo) error {
+		found[current.key(path)] = f
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Update(func(m *Map) ([]*Change, error) {
+		changes := []*Change{}
+
+		for name, f := range found {
+			if m.isCurrent(name, f) {
+				continue
+			}
+			log.Printf("Detected change in %s", name)
+			updated, err := m.update(name, f)
+			if err != nil {
+				return changes, fmt.Errorf("Failed reloading %s: %v", name, err)
+			}
+			changes = append(changes, updated...)
+		}
+
+		for _, name := range m.missing(found) {
+			log.Printf("Detected removal of %s", name)
+			changes = append(changes, m.remove(name)...)
+		}
+		return changes, nil
+	})
+}
+
+// isCurrent indicates whether the Map already has the latest version of a
+// file.
+func (m *Map) isCurrent(name string, f os.FileInfo) bool {
+	if source, isSource := m.sources[name]; isSource {
+		return !f.ModTime().After(source.Modified)
+	}
+	if info, exists := m.Files[name]; exists {
+		return !f.ModTime().After(info.Modified)
+	}
+	return false
+}
+
+// missing lists paths of Map entries that weren't found in the folder.
+func (m *Map) missing(found map[string]os.FileInfo) []string {
+	paths := []string{}
+
+	for _, info := range m.Files {
+		if _, isDerived := m.derived[info.Path]; isDerived {
+			continue
+		}
+		if _, exists := found[info.Path]; !exists {
+			paths = append(paths, info.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,182 @@
+package file_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+func writeTemp(t *testing.T, path, content string, modified time.Time) {
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	assert.NoError(t, err)
+	err = os.Chtimes(path, modified, modified)
+	assert.NoError(t, err)
+}
+
+// TestUpdateChangedFiles ensures polling picks up created, updated and
+// removed files and re-renders entries derived from a changed source.
+func TestUpdateChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("test", "watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now()
+
+	writeTemp(t, filepath.Join(dir, "keep.txt"), "keep", earlier)
+	writeTemp(t, filepath.Join(dir, "delete.txt"), "delete", earlier)
+	writeTemp(t, filepath.Join(dir, "template.html"), "<p>{name}</p>", earlier)
+
+	m, err := file.InFolder(folder+slash+filepath.Base(dir), true)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Read(true))
+
+	template := m.Files["template.html"]
+	delete(m.Files, "template.html")
+	m.Derive("module", template, func(_ *file.Map, source *file.Info) *file.Info {
+		return source.Replace("{name}", "module")
+	})
+	assert.Equal(t, "<p>module</p>", string(m.Files["module"].Content))
+
+	cache := file.NewCache(m)
+	// subscribers can't be removed so this one stops recording when the
+	// test ends
+	var mu sync.Mutex
+	recording := true
+	changes := map[string]file.Op{}
+	file.Subscribe(func(c *file.Change) {
+		mu.Lock()
+		defer mu.Unlock()
+		if recording {
+			changes[c.Key] = c.Op
+		}
+	})
+	defer func() {
+		mu.Lock()
+		recording = false
+		mu.Unlock()
+	}()
+
+	writeTemp(t, filepath.Join(dir, "keep.txt"), "kept", later)
+	writeTemp(t, filepath.Join(dir, "new.txt"), "new", later)
+	writeTemp(t, filepath.Join(dir, "template.html"), "<b>{name}</b>", later)
+	assert.NoError(t, os.Remove(filepath.Join(dir, "delete.txt")))
+
+	err = file.UpdateChangedFiles(cache)
+	assert.NoError(t, err)
+
+	// earlier snapshot is unchanged
+	assert.Equal(t, "keep", string(m.Files["keep.txt"].Content))
+	m = cache.Map()
+
+	assert.Equal(t, "kept", string(m.Files["keep.txt"].Content))
+	assert.Equal(t, "4", m.Files["keep.txt"].Header["Content-Length"])
+	assert.Equal(t, "new", string(m.Files["new.txt"].Content))
+	assert.Equal(t, "<b>module</b>", string(m.Files["module"].Content))
+	assert.NotContains(t, m.Files, "delete.txt")
+	assert.NotContains(t, m.Files, "template.html")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]file.Op{
+		"keep.txt":   file.Updated,
+		"new.txt":    file.Created,
+		"module":     file.Updated,
+		"delete.txt": file.Removed,
+	}, changes)
+}
+
+// TestIntegrityRerender ensures entries derived from a template are
+// re-rendered with the new integrity of a changed script.
+func TestIntegrityRerender(t *testing.T) {
+	dir, err := ioutil.TempDir("test", "integrity")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeTemp(t, filepath.Join(dir, "app.js"), "var a = 1;", time.Now().Add(-time.Hour))
+	writeTemp(t, filepath.Join(dir, "template.html"), "{integrity}", time.Now().Add(-time.Hour))
+
+	m, err := file.InFolder(folder+slash+filepath.Base(dir), true)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Read(true))
+
+	before := m.Files["app.js"].Integrity
+	assert.Contains(t, before, "sha384-")
+	assert.Empty(t, m.Files["template.html"].Integrity)
+
+	template := m.Files["template.html"]
+	delete(m.Files, "template.html")
+	m.Derive("module", template, func(m *file.Map, source *file.Info) *file.Info {
+		return source.Replace("{integrity}", m.Files["app.js"].Integrity)
+	})
+	assert.Equal(t, before, string(m.Files["module"].Content))
+
+	cache := file.NewCache(m)
+	writeTemp(t, filepath.Join(dir, "app.js"), "var a = 2;", time.Now())
+	assert.NoError(t, file.UpdateChangedFiles(cache))
+
+	after := cache.Map().Files["app.js"].Integrity
+	assert.NotEqual(t, before, after)
+	assert.Equal(t, after, string(cache.Map().Files["module"].Content))
+}
+
+// TestMonitor ensures operating system notifications of created, renamed
+// and removed files and of new folders update the cached Map.
+func TestMonitor(t *testing.T) {
+	dir, err := ioutil.TempDir("test", "monitor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeTemp(t, filepath.Join(dir, "keep.txt"), "keep", time.Now())
+
+	m, err := file.InFolder(folder+slash+filepath.Base(dir), true)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Read(true))
+
+	cache := file.NewCache(m)
+	file.Monitor(cache)
+
+	has := func(key string) func() bool {
+		return func() bool {
+			_, exists := cache.Map().Files[key]
+			return exists
+		}
+	}
+	missing := func(key string) func() bool {
+		return func() bool { return !has(key)() }
+	}
+
+	writeTemp(t, filepath.Join(dir, "new.txt"), "new", time.Now())
+	assert.Eventually(t, has("new.txt"), 2*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, os.Rename(filepath.Join(dir, "new.txt"), filepath.Join(dir, "moved.txt")))
+	assert.Eventually(t, has("moved.txt"), 2*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, missing("new.txt"), 2*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "keep.txt")))
+	assert.Eventually(t, missing("keep.txt"), 2*time.Second, 10*time.Millisecond)
+
+	// a folder moved into place already has files and is then watched
+	outside, err := ioutil.TempDir("test", "outside")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outside)
+	writeTemp(t, filepath.Join(outside, "inner.txt"), "inner", time.Now())
+
+	assert.NoError(t, os.Rename(outside, filepath.Join(dir, "sub")))
+	assert.Eventually(t, has("sub/inner.txt"), 2*time.Second, 10*time.Millisecond)
+
+	writeTemp(t, filepath.Join(dir, "sub", "later.txt"), "later", time.Now())
+	assert.Eventually(t, has("sub/later.txt"), 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "later", string(cache.Map().Files["sub/later.txt"].Content))
+
+	// the monitor can't be stopped so its removals are published before the
+	// test ends rather than during later tests
+	assert.NoError(t, os.RemoveAll(dir))
+	assert.Eventually(t, func() bool { return len(cache.Map().Files) == 0 }, 2*time.Second, 10*time.Millisecond)
+}
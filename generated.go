@@ -0,0 +1,48 @@
+package coreweb
+
+import (
+	"sync"
+
+	"github.com/toba/coreweb/file"
+)
+
+type (
+	// generate creates files for a cache snapshot keyed by their path.
+	generate func(c Config, m *file.Map, modules routes) map[string]*file.Info
+
+	// generated holds files created for the most recent cache snapshot so
+	// they're regenerated whenever it's replaced.
+	generated struct {
+		sync.Mutex
+		keys   map[string]bool
+		create generate
+		m      *file.Map
+		files  map[string]*file.Info
+	}
+)
+
+// newGenerated creates a holder for files with the given keys.
+func newGenerated(create generate, keys ...string) *generated {
+	g := &generated{create: create, keys: make(map[string]bool)}
+	for _, k := range keys {
+		g.keys[k] = true
+	}
+	return g
+}
+
+// find returns the file generated for the key, if any, creating files for
+// the snapshot if it has changed.
+func (g *generated) find(c Config, m *file.Map, modules routes, key string) (*file.Info, bool) {
+	if !g.keys[key] {
+		return nil, false
+	}
+	g.Lock()
+	defer g.Unlock()
+
+	if g.m != m {
+		g.files = g.create(c, m, modules)
+		g.m = m
+	}
+	info, ok := g.files[key]
+	return info, ok
+}
@@ -0,0 +1,205 @@
+package coreweb
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/accept"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/imaging"
+	"github.com/toba/coreweb/mime"
+)
+
+// siteFiles returns static files with a template naming the module and
+// locale, a style sheet it preloads and an image.
+func siteFiles(t *testing.T) fstest.MapFS {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 300))))
+
+	return fstest.MapFS{
+		"html/template.html": {Data: []byte(`<html lang="{locale}"><head>` +
+			`<link rel="stylesheet" href="/css/app.css"></head><body>{name}</body></html>`)},
+		"css/app.css":   {Data: []byte("body { margin: 0; }")},
+		"img/photo.png": {Data: buf.Bytes()},
+	}
+}
+
+// serve starts a server for Handle, restoring the previous site when the
+// test ends.
+func serve(t *testing.T, c Config, modulePaths ...string) *httptest.Server {
+	previous := static
+	srv := httptest.NewServer(http.HandlerFunc(Handle(c, modulePaths, nil)))
+
+	t.Cleanup(func() {
+		srv.Close()
+		static = previous
+	})
+	return srv
+}
+
+// fetch requests a path, returning the response and its body.
+func fetch(t *testing.T, srv *httptest.Server, path string, headers map[string]string) (*http.Response, string) {
+	r, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	assert.NoError(t, err)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Do(r)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return res, string(body)
+}
+
+func TestHandleEarlyHints(t *testing.T) {
+	srv := serve(t, Config{FS: siteFiles(t), EarlyHints: true}, "app")
+	link := "</css/app.css>; rel=preload; as=style"
+
+	hints := []int{}
+	hintLinks := []string{}
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+			hints = append(hints, code)
+			hintLinks = append(hintLinks, h.Get(header.Link))
+			return nil
+		},
+	}
+	r, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace),
+		http.MethodGet, srv.URL+"/app", nil)
+	assert.NoError(t, err)
+	res, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	assert.Equal(t, []int{http.StatusEarlyHints}, hints)
+	assert.Equal(t, []string{link}, hintLinks)
+
+	// the final response is unaffected by the hints sent before it
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, mime.HTML, res.Header.Get(content.Type))
+	assert.Equal(t, link, res.Header.Get(header.Link))
+	assert.Contains(t, string(body), "<body>app</body>")
+
+	// files without preloads get no hints
+	hints = hints[:0]
+	r, _ = http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace),
+		http.MethodGet, srv.URL+"/css/app.css", nil)
+	res, err = http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Empty(t, hints)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// nor do conditional requests answered without the page
+	assert.NoError(t, static.cache.Update(func(m *file.Map) ([]*file.Change, error) {
+		page := *m.Files["app"]
+		page.Header = map[string]string{header.ETag: `"v1"`}
+		for k, v := range m.Files["app"].Header {
+			page.Header[k] = v
+		}
+		m.Files["app"] = &page
+		return []*file.Change{{Key: "app", Info: &page, Op: file.Updated}}, nil
+	}))
+	hints = hints[:0]
+	r, _ = http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace),
+		http.MethodGet, srv.URL+"/app", nil)
+	r.Header.Set(header.IfNoneMatch, `"v1"`)
+	res, err = http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Empty(t, hints)
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+}
+
+func TestHandleLocales(t *testing.T) {
+	srv := serve(t, Config{FS: siteFiles(t), Locales: []string{"en", "de"}}, "app")
+
+	res, body := fetch(t, srv, "/de/app", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "de", res.Header.Get(content.Language))
+	assert.Contains(t, body, `lang="de"`)
+
+	res, body = fetch(t, srv, "/app", map[string]string{accept.Language: "de-AT, en;q=0.5"})
+	assert.Equal(t, "de", res.Header.Get(content.Language))
+	assert.Contains(t, body, `lang="de"`)
+	assert.Contains(t, res.Header.Values(header.Vary), accept.Language)
+
+	res, body = fetch(t, srv, "/app", nil)
+	assert.Equal(t, "en", res.Header.Get(content.Language))
+	assert.Contains(t, body, `lang="en"`)
+}
+
+func TestHandleRoutes(t *testing.T) {
+	srv := serve(t, Config{FS: siteFiles(t), TrailingSlash: true}, "app", "app/admin")
+
+	_, body := fetch(t, srv, "/app/admin/users/", nil)
+	assert.Contains(t, body, "<body>app/admin</body>")
+
+	_, body = fetch(t, srv, "/app/view/", nil)
+	assert.Contains(t, body, "<body>app</body>")
+
+	res, _ := fetch(t, srv, "/app", nil)
+	assert.Equal(t, http.StatusMovedPermanently, res.StatusCode)
+	assert.Equal(t, "/app/", res.Header.Get("Location"))
+
+	res, _ = fetch(t, srv, "/missing.txt", nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestHandleCrawl(t *testing.T) {
+	c := Config{FS: siteFiles(t), BaseURL: "https://example.com", Pages: map[string]Page{"admin": {NoIndex: true}}}
+	srv := serve(t, c, "app", "admin")
+
+	res, body := fetch(t, srv, "/robots.txt", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, "Sitemap: https://example.com/sitemap.xml")
+	assert.Contains(t, body, "Disallow: /admin/")
+
+	res, body = fetch(t, srv, "/sitemap.xml", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, "<loc>https://example.com/app</loc>")
+	assert.NotContains(t, body, "/admin")
+}
+
+func TestHandleImages(t *testing.T) {
+	c := Config{FS: siteFiles(t), ImagePresets: map[string]ImagePreset{
+		"thumb": {Width: 100, Height: 100, Fit: imaging.Cover, Format: imaging.JPEG},
+	}}
+	srv := serve(t, c, "app")
+
+	res, body := fetch(t, srv, "/img/photo.png?preset=thumb", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, mime.JPEG, res.Header.Get(content.Type))
+	decoded, _, err := image.Decode(bytes.NewReader([]byte(body)))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(100, 100), decoded.Bounds().Size())
+
+	tag := res.Header.Get(header.ETag)
+	assert.NotEmpty(t, tag)
+	res, _ = fetch(t, srv, "/img/photo.png?preset=thumb", map[string]string{header.IfNoneMatch: tag})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	res, _ = fetch(t, srv, "/img/photo.png?preset=thumb&q=7", nil)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, _ = fetch(t, srv, "/img/photo.png", nil)
+	assert.Equal(t, mime.PNG, res.Header.Get(content.Type))
+}
@@ -3,6 +3,8 @@ package content
 
 const (
 	Encoding = "Content-Encoding"
+	// Language is the locale of the content for its intended audience.
+	Language = "Content-Language"
 	// Length indicates file size in (8-bit) bytes
 	Length = "Content-Length"
 	Type   = "Content-Type"
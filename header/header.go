@@ -2,20 +2,35 @@
 package header
 
 const (
-	Accept       = "Accept"
-	CacheControl = "Cache-Control"
-	Connection   = "Connection"
-	DoNotTrack   = "dnt"
-	eTag         = "ETag"
-	Host         = "Host"
+	Accept        = "Accept"
+	Authorization = "Authorization"
+	// BundleVersion identifies the static file bundle a response came from.
+	BundleVersion = "X-Bundle-Version"
+	CacheControl  = "Cache-Control"
+	Connection    = "Connection"
+	Cookie        = "Cookie"
+	// ContentTypeOptions set to "nosniff" keeps browsers from interpreting
+	// content as a different type than declared.
+	ContentTypeOptions = "X-Content-Type-Options"
+	DoNotTrack         = "dnt"
+	// ETag identifies a version of the response content.
+	ETag = "ETag"
+	Host = "Host"
+	// IfNoneMatch lists ETags the client has cached.
+	IfNoneMatch = "If-None-Match"
 	// LastModified is the RFC1123 time the file was modified.
 	// Example: Tue, 15 Nov 1994 12:45:26 GMT
-	LastModified   = "Last-Modified"
+	LastModified = "Last-Modified"
+	// Link lists resources related to the response, such as those to
+	// preload.
+	Link           = "Link"
 	Origin         = "Origin"
 	Referer        = "Referer"
 	ResponseTime   = "Response-Time"
 	RequestedWidth = "X-Requested-With"
-	UserAgent      = "User-Agent"
+	// RetryAfter is the seconds a client should wait before trying again.
+	RetryAfter = "Retry-After"
+	UserAgent  = "User-Agent"
 	// Vary indicates header keys whose values can vary while still considering
 	// the page to be cached.
 	Vary = "Vary"
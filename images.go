@@ -0,0 +1,241 @@
+package coreweb
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/imaging"
+	"github.com/toba/coreweb/mime"
+)
+
+const (
+	// defaultImageCacheSize is the bytes of transformed images held when
+	// Config.ImageCacheSize isn't set.
+	defaultImageCacheSize = 32 << 20
+	// maxImagePixels is the largest source image that will be decoded. The
+	// decoded image and the RGBA copy made while resizing each take up to
+	// four bytes a pixel.
+	maxImagePixels = 16_000_000
+	// maxImageTransforms is how many images may be decoded and resized at
+	// once. Other requests wait their turn.
+	maxImageTransforms = 2
+)
+
+var (
+	errImagePreset = errors.New("image transform is not an allowed preset")
+
+	// transformParameters are query parameters that would change a transform
+	// if they were allowed. They're refused so only presets are cached.
+	transformParameters = []string{"w", "h", "fit", "q", "fm"}
+)
+
+type (
+	// ImagePreset is an allowed image transform, requested by name.
+	ImagePreset struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+		// Fit is "contain" (the default), "cover" or "fill".
+		Fit imaging.Fit `json:"fit"`
+		// Quality of JPEG images from 1 to 100.
+		Quality int `json:"quality"`
+		// Format is "jpeg", "png" or "gif". The default is the source format.
+		Format string `json:"format"`
+	}
+
+	// images is a least-recently-used cache of transformed images kept
+	// within a size limit.
+	images struct {
+		sync.Mutex
+		limit   int64
+		used    int64
+		order   *list.List
+		entries map[string]*list.Element
+		// busy holds a token for each transform in progress.
+		busy chan struct{}
+	}
+
+	// imageEntry is a transformed image and the file it was made from.
+	imageEntry struct {
+		key    string
+		source *file.Info
+		info   *file.Info
+	}
+)
+
+func newImages(limit int64) *images {
+	if limit <= 0 {
+		limit = defaultImageCacheSize
+	}
+	return &images{
+		limit:   limit,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		busy:    make(chan struct{}, maxImageTransforms),
+	}
+}
+
+// imageTransform finds the preset named in a query string. Only presets are
+// allowed, with their own fit, quality and format, so requests can't flood
+// the cache with variants. False is returned if no transform is requested.
+func imageTransform(presets map[string]ImagePreset, query url.Values) (ImagePreset, bool, error) {
+	for _, name := range transformParameters {
+		if _, ok := query[name]; ok {
+			return ImagePreset{}, true, fmt.Errorf("image parameter %q isn't allowed, only preset", name)
+		}
+	}
+	name := query.Get("preset")
+	if name == "" {
+		return ImagePreset{}, false, nil
+	}
+	t, ok := presets[name]
+	if !ok {
+		return t, true, errImagePreset
+	}
+	if !t.Fit.Valid() {
+		return t, true, fmt.Errorf("unknown image fit %q", t.Fit)
+	}
+	if t.Format != "" && imaging.Format(t.Format) == "" {
+		return t, true, imaging.ErrFormat
+	}
+	t.Format = imaging.Format(t.Format)
+
+	return t, true, nil
+}
+
+// isImage indicates whether a file is an image that can be transformed.
+func isImage(info *file.Info) bool {
+	switch info.Header[content.Type] {
+	case mime.PNG, mime.JPEG, mime.GIF:
+		return true
+	}
+	return false
+}
+
+// transform returns the file resized and converted as requested in the
+// query string, or nil if no transform was requested. Results are cached
+// until the source file changes or they're evicted to stay within the limit.
+func (img *images) transform(c Config, m *file.Map, key string, source *file.Info, query url.Values) (*file.Info, error) {
+	t, requested, err := imageTransform(c.ImagePresets, query)
+	if !requested || err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("%s?%d,%d,%s,%d,%s", key, t.Width, t.Height, t.Fit, t.Quality, t.Format)
+
+	if info, ok := img.get(id, source); ok {
+		return info, nil
+	}
+	img.busy <- struct{}{}
+	defer func() { <-img.busy }()
+
+	// another request may have made it while this one waited
+	if info, ok := img.get(id, source); ok {
+		return info, nil
+	}
+	info, err := renderImage(m, key, source, t)
+	if err != nil {
+		return nil, err
+	}
+	img.put(id, source, info)
+
+	return info, nil
+}
+
+// renderImage decodes, resizes and encodes an image.
+func renderImage(m *file.Map, key string, source *file.Info, t ImagePreset) (*file.Info, error) {
+	var r io.ReadSeeker
+	data, err := m.Content(source)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		r = bytes.NewReader(data)
+	} else {
+		f, err := m.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	src, format, err := imaging.Decode(r, maxImagePixels)
+	if err != nil {
+		return nil, err
+	}
+	if t.Format == "" {
+		t.Format = imaging.Format(format)
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.Resize(src, t.Width, t.Height, t.Fit), t.Format, t.Quality); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(key, path.Ext(key)) + "." + t.Format
+	info := file.FromBytes(name, buf.Bytes(), source.Modified)
+	info.Header[header.ETag] = `"` + revision(buf.Bytes()) + `"`
+
+	return info, nil
+}
+
+// get returns a cached transform if it was made from the current source.
+func (img *images) get(id string, source *file.Info) (*file.Info, bool) {
+	img.Lock()
+	defer img.Unlock()
+
+	e, ok := img.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*imageEntry)
+	if entry.source != source {
+		img.remove(e)
+		return nil, false
+	}
+	img.order.MoveToFront(e)
+	return entry.info, true
+}
+
+// put caches a transform, evicting the least recently used as needed to stay
+// within the limit.
+func (img *images) put(id string, source, info *file.Info) {
+	if info.Size > img.limit {
+		return
+	}
+	img.Lock()
+	defer img.Unlock()
+
+	if e, ok := img.entries[id]; ok {
+		img.remove(e)
+	}
+	for img.used+info.Size > img.limit && img.order.Len() > 0 {
+		img.remove(img.order.Back())
+	}
+	img.entries[id] = img.order.PushFront(&imageEntry{key: id, source: source, info: info})
+	img.used += info.Size
+}
+
+// remove drops a cached transform.
+func (img *images) remove(e *list.Element) {
+	entry := e.Value.(*imageEntry)
+	img.order.Remove(e)
+	delete(img.entries, entry.key)
+	img.used -= entry.info.Size
+}
+
+// purge drops all cached transforms.
+func (img *images) purge() {
+	img.Lock()
+	defer img.Unlock()
+
+	img.order.Init()
+	img.entries = make(map[string]*list.Element)
+	img.used = 0
+}
@@ -0,0 +1,108 @@
+package coreweb
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/url"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/imaging"
+	"github.com/toba/coreweb/mime"
+)
+
+var presets = map[string]ImagePreset{
+	"thumb": {Width: 100, Height: 100, Fit: imaging.Cover, Format: imaging.JPEG},
+	"wide":  {Width: 400},
+}
+
+func TestImageTransform(t *testing.T) {
+	for query, expect := range map[string]ImagePreset{
+		"preset=thumb":       presets["thumb"],
+		"preset=wide":        {Width: 400},
+		"preset=wide&v=1234": {Width: 400},
+	} {
+		values, _ := url.ParseQuery(query)
+		transform, requested, err := imageTransform(presets, values)
+		assert.NoError(t, err, query)
+		assert.True(t, requested, query)
+		assert.Equal(t, expect, transform, query)
+	}
+
+	// only presets may be requested so variants can't flood the cache
+	for _, query := range []string{
+		"preset=huge", "w=400", "fm=png", "preset=thumb&q=7&fm=jpeg",
+		"preset=wide&fit=fill", "preset=thumb&w=100",
+	} {
+		values, _ := url.ParseQuery(query)
+		_, requested, err := imageTransform(presets, values)
+		assert.Error(t, err, query)
+		assert.True(t, requested, query)
+	}
+
+	values, _ := url.ParseQuery("v=123")
+	_, requested, err := imageTransform(presets, values)
+	assert.NoError(t, err)
+	assert.False(t, requested)
+}
+
+func TestImageCache(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 600))))
+
+	m, err := file.InFS(fstest.MapFS{"img/photo.png": {Data: buf.Bytes()}})
+	assert.NoError(t, err)
+	source := m.Files["img/photo.png"]
+	c := Config{ImagePresets: presets}
+	img := newImages(0)
+
+	values, _ := url.ParseQuery("preset=thumb")
+	thumb, err := img.transform(c, m, "img/photo.png", source, values)
+	assert.NoError(t, err)
+	assert.Equal(t, mime.JPEG, thumb.Header[content.Type])
+	assert.NotEmpty(t, thumb.Header[header.ETag])
+
+	decoded, _, err := image.Decode(bytes.NewReader(thumb.Content))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(100, 100), decoded.Bounds().Size())
+
+	same, err := img.transform(c, m, "img/photo.png", source, values)
+	assert.NoError(t, err)
+	assert.Equal(t, thumb, same)
+
+	// a changed source isn't served from the cache
+	changed := *source
+	other, err := img.transform(c, m, "img/photo.png", &changed, values)
+	assert.NoError(t, err)
+	assert.False(t, thumb == other)
+
+	// concurrent requests wait for a turn and share the result
+	wide, _ := url.ParseQuery("preset=wide")
+	results := make(chan *file.Info, 4)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			info, err := img.transform(c, m, "img/photo.png", source, wide)
+			assert.NoError(t, err)
+			results <- info
+		}()
+	}
+	first := <-results
+	for i := 1; i < cap(results); i++ {
+		assert.Equal(t, first.Content, (<-results).Content)
+	}
+	assert.Empty(t, img.busy)
+
+	// the cache stays within its limit
+	small := newImages(thumb.Size)
+	small.put("a", source, thumb)
+	small.put("b", source, thumb)
+	_, ok := small.get("a", source)
+	assert.False(t, ok)
+	_, ok = small.get("b", source)
+	assert.True(t, ok)
+}
@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Formats that images can be encoded as.
+const (
+	GIF  = "gif"
+	JPEG = "jpeg"
+	PNG  = "png"
+)
+
+// DefaultQuality is the JPEG quality used when none is given.
+const DefaultQuality = 85
+
+var (
+	ErrFormat = errors.New("unsupported image format")
+	ErrSize   = errors.New("image is too large to transform")
+)
+
+// Format normalizes an image format name, returning an empty string if it
+// can't be encoded.
+func Format(name string) string {
+	switch name {
+	case "jpg", JPEG:
+		return JPEG
+	case PNG:
+		return PNG
+	case GIF:
+		return GIF
+	}
+	return ""
+}
+
+// Decode reads an image, returning its format, if it has no more than
+// maxPixels. The size is checked before decoding so oversized images don't
+// exhaust memory.
+func Decode(r io.ReadSeeker, maxPixels int) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if maxPixels > 0 && config.Width*config.Height > maxPixels {
+		return nil, "", ErrSize
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(r)
+	return img, format, err
+}
+
+// Encode writes an image in the format. Quality from 1 to 100 applies to
+// JPEG, with zero meaning DefaultQuality.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch Format(format) {
+	case JPEG:
+		if quality <= 0 {
+			quality = DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case PNG:
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		return encoder.Encode(w, img)
+	case GIF:
+		return gif.Encode(w, img, nil)
+	}
+	return ErrFormat
+}
@@ -0,0 +1,82 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/imaging"
+)
+
+// checkered creates an image alternating black and white pixels.
+func checkered(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+func TestResize(t *testing.T) {
+	src := checkered(200, 100)
+
+	for _, test := range []struct {
+		width, height int
+		fit           imaging.Fit
+		expect        image.Point
+	}{
+		{50, 50, imaging.Contain, image.Pt(50, 25)},
+		{50, 50, imaging.Cover, image.Pt(50, 50)},
+		{50, 50, imaging.Fill, image.Pt(50, 50)},
+		{100, 0, imaging.Contain, image.Pt(100, 50)},
+		{0, 20, imaging.Cover, image.Pt(40, 20)},
+		{400, 400, imaging.Contain, image.Pt(200, 100)},
+		{0, 0, imaging.Fill, image.Pt(200, 100)},
+	} {
+		img := imaging.Resize(src, test.width, test.height, test.fit)
+		assert.Equal(t, test.expect, img.Bounds().Size(), "%dx%d %s", test.width, test.height, test.fit)
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	img := imaging.Resize(checkered(4, 4), 2, 2, imaging.Fill)
+	r, g, b, a := img.At(0, 0).RGBA()
+
+	// a checkered block averages to gray rather than black or white
+	assert.InDelta(t, 0x7f7f, r, 0x101)
+	assert.Equal(t, r, g)
+	assert.Equal(t, r, b)
+	assert.Equal(t, uint32(0xffff), a)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, checkered(20, 10)))
+
+	img, format, err := imaging.Decode(bytes.NewReader(buf.Bytes()), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, imaging.PNG, format)
+
+	_, _, err = imaging.Decode(bytes.NewReader(buf.Bytes()), 100)
+	assert.Equal(t, imaging.ErrSize, err)
+
+	for _, format := range []string{"jpg", imaging.JPEG, imaging.PNG, imaging.GIF} {
+		var out bytes.Buffer
+		assert.NoError(t, imaging.Encode(&out, img, format, 0))
+
+		decoded, decodedFormat, err := imaging.Decode(bytes.NewReader(out.Bytes()), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, imaging.Format(format), decodedFormat)
+		assert.Equal(t, image.Pt(20, 10), decoded.Bounds().Size())
+	}
+	assert.Equal(t, imaging.ErrFormat, imaging.Encode(&buf, img, "webp", 0))
+}
@@ -0,0 +1,142 @@
+// Package imaging resizes, crops and re-encodes images using only the
+// standard library. Downscaling averages the source pixels covered by each
+// destination pixel, which avoids the aliasing of nearest neighbor sampling
+// without the cost of higher order filters.
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Fit is how an image is sized to a requested width and height.
+type Fit string
+
+const (
+	// Contain scales the image to fit within the size, keeping its aspect
+	// ratio. Images are never enlarged.
+	Contain Fit = "contain"
+	// Cover scales the image to cover the size, keeping its aspect ratio,
+	// and crops the excess from its center.
+	Cover Fit = "cover"
+	// Fill stretches the image to exactly the size.
+	Fill Fit = "fill"
+)
+
+// Valid indicates whether the fit is known. An empty fit is treated as
+// Contain.
+func (f Fit) Valid() bool {
+	switch f {
+	case "", Contain, Cover, Fill:
+		return true
+	}
+	return false
+}
+
+// Resize sizes an image to the width and height according to the fit. If
+// either dimension is zero it's calculated from the other using the image's
+// aspect ratio. If both are zero the image is returned as it is.
+func Resize(src image.Image, width, height int, fit Fit) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	if (width <= 0 && height <= 0) || sw == 0 || sh == 0 {
+		return src
+	}
+	if width <= 0 {
+		width = atLeastOne(sw * height / sh)
+		fit = Fill
+	}
+	if height <= 0 {
+		height = atLeastOne(sh * width / sw)
+		fit = Fill
+	}
+
+	switch fit {
+	case Fill:
+		return scale(src, b, width, height)
+
+	case Cover:
+		// crop the source to the target aspect ratio then scale
+		crop := b
+		if sw*height > sh*width {
+			cw := sh * width / height
+			crop.Min.X += (sw - cw) / 2
+			crop.Max.X = crop.Min.X + cw
+		} else {
+			ch := sw * height / width
+			crop.Min.Y += (sh - ch) / 2
+			crop.Max.Y = crop.Min.Y + ch
+		}
+		return scale(src, crop, width, height)
+
+	default:
+		if width >= sw && height >= sh {
+			return src
+		}
+		if sw*height > sh*width {
+			height = atLeastOne(sh * width / sw)
+		} else {
+			width = atLeastOne(sw * height / sh)
+		}
+		return scale(src, b, width, height)
+	}
+}
+
+// scale resamples the region of an image to the width and height. Each
+// destination pixel is the average of the source pixels it covers, or the
+// nearest source pixel when enlarging.
+func scale(src image.Image, region image.Rectangle, width, height int) *image.RGBA {
+	// premultiplied color is averaged so transparent pixels don't bleed
+	rgba := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, region.Min, draw.Src)
+
+	sw, sh := region.Dx(), region.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, height, sh)
+
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, width, sw)
+			var r, g, b, a, n uint32
+
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					b += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span returns the range of source pixels covered by destination pixel i of
+// n when scaling to n from size pixels. The range is never empty.
+func span(i, n, size int) (int, int) {
+	start := i * size / n
+	end := (i + 1) * size / n
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// atLeastOne keeps calculated dimensions from reaching zero.
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
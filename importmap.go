@@ -0,0 +1,201 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/toba/coreweb/file"
+)
+
+// importMapToken is replaced in the template with the import map and module
+// preload links. Without it they're added at the start of the head, since
+// browsers ignore an import map that follows a module script.
+const importMapToken = "{importmap}"
+
+var (
+	// importPattern matches static import and export statements to find the
+	// module specifier they load.
+	importPattern = regexp.MustCompile(`(?:^|[;\s}])(?:import|export)\s*(?:[\w$*{}\s,]+?\s*from\s*)?["']([^"']+)["']`)
+	// headStart matches the opening head tag.
+	headStart = regexp.MustCompile(`(?i)<head\b[^>]*>`)
+)
+
+// importManifest maps bare module specifiers, like "lit" or "lib/", to the
+// URLs of static files and names the entry script of each module path.
+//
+//	{
+//	   "imports": { "lit": "/js/vendor/lit.js", "lib/": "/js/lib/" },
+//	   "modules": { "app": "/js/app/main.js" }
+//	}
+type importManifest struct {
+	Imports map[string]string `json:"imports"`
+	Modules map[string]string `json:"modules"`
+}
+
+// readImportManifest parses the import manifest from the static files.
+func readImportManifest(m *file.Map, key string) (*importManifest, error) {
+	info, exists := m.Files[key]
+	if !exists {
+		return nil, nil
+	}
+	data, err := m.Content(info)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &importManifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// importMarkup creates the import map script, with URLs fingerprinted by
+// content revision, followed by modulepreload links for every script the
+// module entry statically imports, directly or indirectly.
+func importMarkup(m *file.Map, key, module string) string {
+	manifest, err := readImportManifest(m, key)
+	if err != nil {
+		log.Printf("Unable to read import manifest %s: %v", key, err)
+		return ""
+	}
+	if manifest == nil {
+		return ""
+	}
+	imports := make(map[string]string, len(manifest.Imports))
+	for specifier, url := range manifest.Imports {
+		imports[specifier] = fingerprint(m, url)
+	}
+	data, err := json.Marshal(map[string]interface{}{"imports": imports})
+	if err != nil {
+		log.Printf("Unable to create import map: %v", err)
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<script type="importmap">`)
+	b.Write(data)
+	b.WriteString(`</script>`)
+
+	if entry, ok := manifest.Modules[module]; ok {
+		for _, url := range dependencies(m, manifest, entry) {
+			b.WriteString(`<link rel="modulepreload" href="` + url + `">`)
+		}
+	}
+	return b.String()
+}
+
+// fingerprint adds the revision of a static file's content to its URL so it
+// can be cached until it changes. Prefixes and other URLs are unchanged.
+func fingerprint(m *file.Map, url string) string {
+	if strings.HasSuffix(url, webSlash) || strings.Contains(url, "//") {
+		return url
+	}
+	info, exists := m.Files[strings.TrimPrefix(url, webSlash)]
+	if !exists {
+		return url
+	}
+	data, err := m.Content(info)
+	if err != nil || data == nil {
+		return url
+	}
+	return url + "?v=" + revision(data)
+}
+
+// dependencies lists the URL of the entry script and of every static file
+// it imports, directly or indirectly, in the order they're found. URLs are
+// those the browser will request: only bare specifiers mapped exactly by the
+// import map are fingerprinted, while the entry, relative imports and
+// prefix mappings load the plain URL.
+func dependencies(m *file.Map, manifest *importManifest, entry string) []string {
+	type pending struct{ url, requested string }
+
+	urls := []string{}
+	requested := map[string]bool{}
+	scanned := map[string]bool{}
+	queue := []pending{{entry, entry}}
+
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		key := strings.TrimPrefix(next.url, webSlash)
+
+		info, exists := m.Files[key]
+		if !exists || requested[next.requested] {
+			continue
+		}
+		requested[next.requested] = true
+		urls = append(urls, next.requested)
+
+		if scanned[key] {
+			continue
+		}
+		scanned[key] = true
+
+		data, err := m.Content(info)
+		if err != nil || data == nil {
+			continue
+		}
+		for _, match := range importPattern.FindAllSubmatch(data, -1) {
+			specifier := string(match[1])
+			resolved, ok := manifest.resolve(next.url, specifier)
+			if !ok {
+				continue
+			}
+			if _, exact := manifest.Imports[specifier]; exact {
+				queue = append(queue, pending{resolved, fingerprint(m, resolved)})
+			} else {
+				queue = append(queue, pending{resolved, resolved})
+			}
+		}
+	}
+	return urls
+}
+
+// resolve converts a module specifier imported by the script at url to the
+// URL of a static file.
+func (manifest *importManifest) resolve(url, specifier string) (string, bool) {
+	switch {
+	case strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../"):
+		return path.Join(path.Dir(url), specifier), true
+	case strings.HasPrefix(specifier, webSlash):
+		return specifier, true
+	}
+	if mapped, ok := manifest.Imports[specifier]; ok {
+		return mapped, true
+	}
+	// the longest matching prefix applies
+	prefixes := []string{}
+	for p := range manifest.Imports {
+		if strings.HasSuffix(p, webSlash) && strings.HasPrefix(specifier, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	if len(prefixes) == 0 {
+		return "", false
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	p := prefixes[0]
+
+	return manifest.Imports[p] + strings.TrimPrefix(specifier, p), true
+}
+
+// importMapPairs lists the replacement adding import markup to a module
+// page, for use with Info.ReplaceAll.
+func importMapPairs(c Config, m *file.Map, template, module string) []string {
+	if c.ImportMap == "" {
+		return nil
+	}
+	markup := importMarkup(m, c.ImportMap, module)
+
+	if strings.Contains(template, importMapToken) {
+		return []string{importMapToken, markup}
+	}
+	head := headStart.FindString(template)
+	if markup == "" || head == "" {
+		return nil
+	}
+	return []string{head, head + markup}
+}
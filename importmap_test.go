@@ -0,0 +1,81 @@
+package coreweb
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+)
+
+func TestImportMap(t *testing.T) {
+	main := []byte(`import { html } from "lit";
+import "./view.js";
+export * from "lib/util.js";`)
+	view := []byte(`import { html } from "lit";`)
+	lit := []byte(`export const html = 1;`)
+	util := []byte(`export const util = 1;`)
+
+	m, err := file.InFS(fstest.MapFS{
+		"html/template.html": {Data: []byte(`<html><head><script type="module" src="/js/app/main.js"></script></head></html>`)},
+		"importmap.json": {Data: []byte(`{
+			"imports": {"lit": "/js/vendor/lit.js", "lib/": "/js/lib/"},
+			"modules": {"app": "/js/app/main.js"}
+		}`)},
+		"js/app/main.js":    {Data: main},
+		"js/app/view.js":    {Data: view},
+		"js/vendor/lit.js":  {Data: lit},
+		"js/lib/util.js":    {Data: util},
+		"js/other/other.js": {Data: util},
+	})
+	assert.NoError(t, err)
+	m, err = build(Config{ImportMap: "importmap.json"}, m, []string{"app", "other"})
+	assert.NoError(t, err)
+
+	// the map precedes module scripts and preloads are of the URLs the page
+	// requests: fingerprinted only for exact bare specifiers
+	assert.Equal(t, `<html><head>`+
+		`<script type="importmap">{"imports":{"lib/":"/js/lib/","lit":"/js/vendor/lit.js?v=`+revision(lit)+`"}}</script>`+
+		`<link rel="modulepreload" href="/js/app/main.js">`+
+		`<link rel="modulepreload" href="/js/vendor/lit.js?v=`+revision(lit)+`">`+
+		`<link rel="modulepreload" href="/js/app/view.js">`+
+		`<link rel="modulepreload" href="/js/lib/util.js">`+
+		`<script type="module" src="/js/app/main.js"></script></head></html>`,
+		string(m.Files["app"].Content))
+
+	assert.Equal(t, "</js/app/main.js>; rel=modulepreload, "+
+		"</js/vendor/lit.js?v="+revision(lit)+">; rel=modulepreload, "+
+		"</js/app/view.js>; rel=modulepreload, "+
+		"</js/lib/util.js>; rel=modulepreload",
+		m.Files["app"].Header[header.Link])
+
+	assert.Equal(t, `<html><head>`+
+		`<script type="importmap">{"imports":{"lib/":"/js/lib/","lit":"/js/vendor/lit.js?v=`+revision(lit)+`"}}</script>`+
+		`<script type="module" src="/js/app/main.js"></script></head></html>`,
+		string(m.Files["other"].Content))
+}
+
+func TestImportResolve(t *testing.T) {
+	manifest := &importManifest{Imports: map[string]string{
+		"lit":      "/js/lit.js",
+		"lib/":     "/js/lib/",
+		"lib/ui/":  "/js/ui/",
+		"external": "https://cdn.example.com/x.js",
+	}}
+	for specifier, expect := range map[string]string{
+		"./b.js":      "/js/app/b.js",
+		"../c.js":     "/js/c.js",
+		"/js/d.js":    "/js/d.js",
+		"lit":         "/js/lit.js",
+		"lib/a.js":    "/js/lib/a.js",
+		"lib/ui/b.js": "/js/ui/b.js",
+		"external":    "https://cdn.example.com/x.js",
+	} {
+		resolved, ok := manifest.resolve("/js/app/a.js", specifier)
+		assert.True(t, ok, specifier)
+		assert.Equal(t, expect, resolved, specifier)
+	}
+	_, ok := manifest.resolve("/js/app/a.js", "unknown")
+	assert.False(t, ok)
+}
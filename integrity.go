@@ -0,0 +1,74 @@
+package coreweb
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/toba/coreweb/file"
+)
+
+var (
+	// integrityToken is replaced in the template with the Subresource
+	// Integrity digest of the named file, as in {integrity:/js/app.js}.
+	integrityToken = regexp.MustCompile(`\{integrity:([^}]+)\}`)
+	// scriptOrStyle matches script and link tags with the URL they load.
+	scriptOrStyle = regexp.MustCompile(`<(?:script|link)\b[^>]*?\b(?:src|href)\s*=\s*["']([^"']+)["'][^>]*>`)
+)
+
+// integrityPairs lists integrity tokens in a template, each followed by the
+// digest it should be replaced with, for use with Info.ReplaceAll. If tags is
+// set then script and link tags loading local scripts or style sheets, that
+// don't already have one, are followed by the tag with an integrity
+// attribute added. Digests are of the files served in the locale, which may
+// be localized variants.
+func integrityPairs(m *file.Map, template string, tags bool, locale string) []string {
+	pairs := []string{}
+
+	for _, match := range integrityToken.FindAllStringSubmatch(template, -1) {
+		pairs = append(pairs, match[0], integrity(m, match[1], locale))
+	}
+	if !tags {
+		return pairs
+	}
+	for _, match := range scriptOrStyle.FindAllStringSubmatch(template, -1) {
+		tag := match[0]
+		digest := integrity(m, match[1], locale)
+
+		if digest == "" || strings.Contains(tag, "integrity=") {
+			continue
+		}
+		attributes := ` integrity="` + digest + `"`
+		if !strings.Contains(tag, "crossorigin") {
+			attributes += ` crossorigin="anonymous"`
+		}
+		end := len(tag) - 1
+		if strings.HasSuffix(tag, "/>") {
+			end--
+		}
+		pairs = append(pairs, tag, strings.TrimRight(tag[:end], " ")+attributes+tag[end:])
+	}
+	return pairs
+}
+
+// integrity returns the digest of a local script or style sheet, or of its
+// variant for the locale since that's what find serves for the same URL, or
+// an empty string for other URLs.
+func integrity(m *file.Map, url, locale string) string {
+	if strings.Contains(url, "//") {
+		return ""
+	}
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	key := strings.TrimPrefix(url, webSlash)
+
+	if locale != "" {
+		if info, exists := m.Files[variant(key, locale)]; exists {
+			return info.Integrity
+		}
+	}
+	if info, exists := m.Files[key]; exists {
+		return info.Integrity
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package coreweb
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+func TestTemplateIntegrity(t *testing.T) {
+	script := []byte("var a = 1;")
+	hash := sha512.Sum384(script)
+	digest := "sha384-" + base64.StdEncoding.EncodeToString(hash[:])
+
+	m, err := file.InFS(fstest.MapFS{
+		"html/template.html": {Data: []byte(`<script src="/js/app.js"></script>` +
+			`<link rel="stylesheet" href="https://cdn.example.com/site.css"/>` +
+			`<meta content="{integrity:/js/app.js}">`)},
+		"js/app.js": {Data: script},
+	})
+	assert.NoError(t, err)
+	m, err = build(Config{Integrity: true}, m, []string{"app"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, digest, m.Files["js/app.js"].Integrity)
+	assert.Equal(t,
+		`<script src="/js/app.js" integrity="`+digest+`" crossorigin="anonymous"></script>`+
+			`<link rel="stylesheet" href="https://cdn.example.com/site.css"/>`+
+			`<meta content="`+digest+`">`,
+		string(m.Files["app"].Content))
+}
+
+func TestIntegrityPairs(t *testing.T) {
+	m := &file.Map{Files: map[string]*file.Info{
+		"css/site.css": {Integrity: "sha384-abc"},
+	}}
+	pairs := integrityPairs(m, `<link rel="stylesheet" href="/css/site.css?v=2" />`, true, "")
+	assert.Equal(t, []string{
+		`<link rel="stylesheet" href="/css/site.css?v=2" />`,
+		`<link rel="stylesheet" href="/css/site.css?v=2" integrity="sha384-abc" crossorigin="anonymous"/>`,
+	}, pairs)
+
+	pairs = integrityPairs(m, `<link href="/css/site.css" integrity="sha384-old">`, true, "")
+	assert.Empty(t, pairs)
+}
+
+func TestLocalizedIntegrity(t *testing.T) {
+	digest := func(data string) string {
+		hash := sha512.Sum384([]byte(data))
+		return "sha384-" + base64.StdEncoding.EncodeToString(hash[:])
+	}
+	m, err := file.InFS(fstest.MapFS{
+		"html/template.html": {Data: []byte(`<script src="/js/strings.js"></script>`)},
+		"js/strings.js":      {Data: []byte(`var hello = "Hello";`)},
+		"js/strings.de.js":   {Data: []byte(`var hello = "Hallo";`)},
+	})
+	assert.NoError(t, err)
+	c := Config{Integrity: true, Locales: []string{"en", "de"}}
+	m, err = build(c, m, []string{"app"})
+	assert.NoError(t, err)
+
+	page := func(locale string) string {
+		info, _, _ := find(c, m, newRoutes([]string{"app"}), "app", locale)
+		return string(info.Content)
+	}
+	assert.Contains(t, page("en"), digest(`var hello = "Hello";`))
+	assert.Contains(t, page("de"), digest(`var hello = "Hallo";`))
+
+	// the digest matches what's served for the script in the locale
+	script, _, _ := find(c, m, nil, "js/strings.js", "de")
+	assert.Contains(t, page("de"), script.Integrity)
+}
@@ -0,0 +1,66 @@
+package coreweb
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// limiter restricts how many requests each client address may make
+	// within a window of time.
+	limiter struct {
+		sync.Mutex
+		max     int
+		window  time.Duration
+		clients map[string]*allowance
+		pruned  time.Time
+	}
+
+	// allowance counts a client's requests in the current window.
+	allowance struct {
+		start time.Time
+		count int
+	}
+)
+
+func newLimiter(max int, window time.Duration) *limiter {
+	return &limiter{max: max, window: window, clients: make(map[string]*allowance)}
+}
+
+// clientAddress identifies the client making a request by its IP address.
+func clientAddress(r *http.Request) string {
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// allow indicates whether the client may make another request, counting it
+// if so.
+func (l *limiter) allow(key string) bool {
+	now := time.Now()
+
+	l.Lock()
+	defer l.Unlock()
+
+	if now.Sub(l.pruned) > l.window {
+		// forget clients whose window has passed so the map doesn't grow
+		for k, a := range l.clients {
+			if now.Sub(a.start) > l.window {
+				delete(l.clients, k)
+			}
+		}
+		l.pruned = now
+	}
+	a, ok := l.clients[key]
+	if !ok || now.Sub(a.start) > l.window {
+		l.clients[key] = &allowance{start: now, count: 1}
+		return true
+	}
+	if a.count >= l.max {
+		return false
+	}
+	a.count++
+	return true
+}
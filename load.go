@@ -0,0 +1,130 @@
+package coreweb
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/toba/coreweb/file"
+)
+
+// site is the static file cache created by Handle along with what's needed
+// to rebuild it. It is shared with administrative handlers.
+type site struct {
+	config      Config
+	modulePaths []string
+	cache       *file.Cache
+	images      *images
+}
+
+// static is the site most recently created by Handle.
+var static *site
+
+// source retrieves the static file Map from the configured file system, zip
+// archive or folder, or from registered zip data.
+func source(c Config) (*file.Map, error) {
+	switch {
+	case c.FS != nil:
+		return file.InFS(c.FS)
+	case c.FromZip != "":
+		return file.InZip(c.FromZip)
+	case file.HasZipData() && c.FromFolder == "":
+		return file.InZipFile()
+	default:
+		// read all files in folder
+		return file.InFolder(c.FromFolder, true)
+	}
+}
+
+// load reads all static files from the configured source and renders the
+// template for each module path.
+func load(c Config, modulePaths []string) (*file.Map, error) {
+	m, err := source(c)
+	if err != nil {
+		return nil, err
+	}
+	return build(c, m, modulePaths)
+}
+
+// build reads the content of all files in a Map and renders the template for
+// each module path.
+func build(c Config, m *file.Map, modulePaths []string) (*file.Map, error) {
+	m.Budget = file.Budget{
+		MaxBytes:     c.MemoryBudget,
+		MaxFileBytes: c.MaxMemoryFileSize,
+	}
+	m.Compression = c.Compression
+	m.Minify = c.Minify
+
+	if err := m.Read(true); err != nil {
+		m.Close()
+		return nil, err
+	}
+	stats := m.CompressionStats()
+	log.Printf("Caching %d static files (minified %d bytes, compressed %d saving %d bytes in %v)",
+		len(m.Files), stats.Minified, stats.Files, stats.Saved, stats.Elapsed)
+
+	files := make(map[string]*file.Info)
+	for k, v := range m.Files {
+		files[webPath(k)] = v
+	}
+	m.Files = files
+
+	if _, there := m.Files[templatePath]; len(m.Files) < 2 || !there {
+		m.Close()
+		return nil, fmt.Errorf("Invalid Template (%d files) for folder \"%s\"", len(m.Files), c.FromFolder)
+	}
+
+	// make single reference to template and remove it from cache array
+	template := m.Files[templatePath]
+	delete(m.Files, templatePath)
+
+	// templates localized like template.de.html replace the default for
+	// other locales
+	templates := make(map[string]*file.Info)
+	base := template
+
+	for i, locale := range c.Locales {
+		localized, there := m.Files[variant(templatePath, locale)]
+		delete(m.Files, variant(templatePath, locale))
+		if !there {
+			localized = base
+		}
+		if i == 0 {
+			template = localized
+		} else {
+			templates[locale] = localized
+		}
+	}
+	locale := defaultLocale(c)
+
+	// add cache entry for template rendered for each module, re-rendered
+	// whenever the template changes
+	for _, name := range modulePaths {
+		log.Printf("Adding module endpoint /%s", name)
+		m.Derive(name, template, renderModule(c, name, locale))
+
+		for l, localized := range templates {
+			m.Derive(localeKey(l, name), localized, renderModule(c, name, l))
+		}
+	}
+	return m, nil
+}
+
+// renderModule creates a function that renders the template for a module in
+// a locale.
+func renderModule(c Config, name, locale string) file.Render {
+	return func(m *file.Map, template *file.Info) *file.Info {
+		pairs := []string{
+			templateToken, name,
+			versionToken, m.Version,
+			localeToken, locale,
+			dirToken, direction(locale),
+		}
+		pairs = append(pairs, integrityPairs(m, string(template.Content), c.Integrity, locale)...)
+		pairs = append(pairs, importMapPairs(c, m, string(template.Content), name)...)
+
+		page := template.ReplaceAll(pairs...)
+
+		return setPreload(page, preloadLinks(c, name, page.Content))
+	}
+}
@@ -0,0 +1,176 @@
+package coreweb
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header/accept"
+)
+
+const (
+	localeToken = "{locale}"
+	dirToken    = "{dir}"
+	// defaultLocaleCookie names the cookie holding a chosen locale when
+	// Config.LocaleCookie isn't set.
+	defaultLocaleCookie = "locale"
+)
+
+// rightToLeft are languages written right to left.
+var rightToLeft = map[string]bool{
+	"ar": true,
+	"dv": true,
+	"fa": true,
+	"he": true,
+	"ps": true,
+	"ur": true,
+	"yi": true,
+}
+
+// direction returns the HTML dir attribute value for a locale.
+func direction(locale string) string {
+	if rightToLeft[language(locale)] {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// language returns the lower case language of a locale, such as "de" for
+// "de-AT".
+func language(locale string) string {
+	return strings.ToLower(strings.SplitN(locale, "-", 2)[0])
+}
+
+// defaultLocale is the first configured locale, if any.
+func defaultLocale(c Config) string {
+	if len(c.Locales) == 0 {
+		return ""
+	}
+	return c.Locales[0]
+}
+
+// supported returns the configured locale matching a language tag exactly
+// or, if allowed, by language alone.
+func supported(c Config, tag string, byLanguage bool) (string, bool) {
+	for _, l := range c.Locales {
+		if strings.EqualFold(l, tag) {
+			return l, true
+		}
+	}
+	if byLanguage {
+		for _, l := range c.Locales {
+			if language(l) == language(tag) {
+				return l, true
+			}
+		}
+	}
+	return "", false
+}
+
+// negotiateLocale chooses the locale for a request from a path prefix, a
+// cookie or the Accept-Language header, in that order, falling back to the
+// first configured locale. The key is returned without any locale prefix.
+func negotiateLocale(c Config, r *http.Request, key string) (locale, rest string) {
+	if len(c.Locales) == 0 {
+		return "", key
+	}
+	if l, rest, ok := localePrefix(c, key); ok {
+		return l, rest
+	}
+	name := c.LocaleCookie
+	if name == "" {
+		name = defaultLocaleCookie
+	}
+	if cookie, err := r.Cookie(name); err == nil {
+		if l, ok := supported(c, cookie.Value, false); ok {
+			return l, key
+		}
+	}
+	for _, tag := range acceptedLanguages(r.Header.Get(accept.Language)) {
+		if l, ok := supported(c, tag, true); ok {
+			return l, key
+		}
+	}
+	return defaultLocale(c), key
+}
+
+// localePrefix returns the locale that a key begins with, if any, and the
+// rest of the key.
+func localePrefix(c Config, key string) (locale, rest string, ok bool) {
+	parts := strings.SplitN(key, webSlash, 2)
+	if l, ok := supported(c, parts[0], false); ok {
+		if len(parts) == 1 {
+			return l, "", true
+		}
+		return l, parts[1], true
+	}
+	return "", key, false
+}
+
+// acceptedLanguages parses an Accept-Language header into language tags,
+// most preferred first.
+func acceptedLanguages(value string) []string {
+	list := []weighted{}
+	for _, w := range parseWeighted(value) {
+		if w.value != "*" && w.q > 0 {
+			list = append(list, w)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+
+	tags := make([]string, len(list))
+	for i, w := range list {
+		tags[i] = w.value
+	}
+	return tags
+}
+
+// localeKey is the key of a module page rendered for a locale other than
+// the default.
+func localeKey(locale, module string) string {
+	return locale + webSlash + module
+}
+
+// variant is the name of a file localized for a locale, such as
+// "strings.fr.json" for "strings.json".
+func variant(name, locale string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + locale + ext
+}
+
+// find returns the static file for a key. Module pages, including those for
+// client-side routes beneath a module, are preferred in the locale and other
+// files have their localized variant preferred. Localized indicates whether
+// the result depends on the locale.
+func find(c Config, m *file.Map, modules routes, key, locale string) (info *file.Info, exists, localized bool) {
+	info, exists = m.Files[key]
+	page, isPage := modules.match(key)
+
+	if exists && page != key {
+		// files beneath a module are served as they are
+		isPage = false
+	}
+	if !exists && !isPage && c.FallbackModule != "" && path.Ext(key) == "" {
+		page, isPage = c.FallbackModule, true
+	}
+	if isPage {
+		localized = len(c.Locales) > 1
+		if l, ok := m.Files[localeKey(locale, page)]; ok && locale != "" {
+			return l, true, localized
+		}
+		info, exists = m.Files[page]
+		return info, exists, localized
+	}
+	for _, l := range c.Locales {
+		if _, ok := m.Files[variant(key, l)]; ok {
+			localized = true
+			break
+		}
+	}
+	if l, ok := m.Files[variant(key, locale)]; ok && locale != "" {
+		return l, true, localized
+	}
+	return info, exists, localized
+}
@@ -0,0 +1,77 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header/accept"
+)
+
+var localeConfig = Config{Locales: []string{"en", "de", "ar"}}
+
+func TestAcceptedLanguages(t *testing.T) {
+	assert.Equal(t,
+		[]string{"fr-CH", "de", "en"},
+		acceptedLanguages("en;q=0.5, fr-CH, *;q=0.1, de;q=0.8, es;q=0"))
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/app", nil)
+
+	locale, key := negotiateLocale(localeConfig, r, "app")
+	assert.Equal(t, "en", locale)
+	assert.Equal(t, "app", key)
+
+	r.Header.Set(accept.Language, "fr, de-AT;q=0.9")
+	locale, _ = negotiateLocale(localeConfig, r, "app")
+	assert.Equal(t, "de", locale)
+
+	r.AddCookie(&http.Cookie{Name: defaultLocaleCookie, Value: "ar"})
+	locale, _ = negotiateLocale(localeConfig, r, "app")
+	assert.Equal(t, "ar", locale)
+
+	locale, key = negotiateLocale(localeConfig, r, "de/app/view")
+	assert.Equal(t, "de", locale)
+	assert.Equal(t, "app/view", key)
+
+	locale, key = negotiateLocale(Config{}, r, "de/app")
+	assert.Equal(t, "", locale)
+	assert.Equal(t, "de/app", key)
+}
+
+func TestLocalizedBuild(t *testing.T) {
+	m, err := file.InFS(fstest.MapFS{
+		"html/template.html":    {Data: []byte(`<html lang="{locale}" dir="{dir}">{name}</html>`)},
+		"html/template.de.html": {Data: []byte(`<html lang="{locale}">Hallo {name}</html>`)},
+		"strings.json":          {Data: []byte(`{"hello":"Hello"}`)},
+		"strings.de.json":       {Data: []byte(`{"hello":"Hallo"}`)},
+		"logo.svg":              {Data: []byte(`<svg></svg>`)},
+	})
+	assert.NoError(t, err)
+	m, err = build(localeConfig, m, []string{"app"})
+	assert.NoError(t, err)
+
+	modules := newRoutes([]string{"app"})
+	for _, expect := range []struct {
+		key, locale, content string
+		localized            bool
+	}{
+		{"app", "en", `<html lang="en" dir="ltr">app</html>`, true},
+		{"app/view", "de", `<html lang="de">Hallo app</html>`, true},
+		{"app", "ar", `<html lang="ar" dir="rtl">app</html>`, true},
+		{"strings.json", "de", `{"hello":"Hallo"}`, true},
+		{"strings.json", "ar", `{"hello":"Hello"}`, true},
+		{"logo.svg", "de", `<svg></svg>`, false},
+	} {
+		info, exists, localized := find(localeConfig, m, modules, expect.key, expect.locale)
+		assert.True(t, exists, expect.key)
+		assert.Equal(t, expect.content, string(info.Content), expect.key)
+		assert.Equal(t, expect.localized, localized, expect.key)
+	}
+	_, exists := m.Files[variant(templatePath, "de")]
+	assert.False(t, exists)
+}
@@ -0,0 +1,120 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+// defaultRetryAfter is the seconds clients are asked to wait before retrying
+// during maintenance when no other time is given.
+const defaultRetryAfter = 300
+
+// Maintenance describes whether the site is down for maintenance.
+type Maintenance struct {
+	Enabled bool `json:"enabled"`
+	// RetryAfter is the seconds clients should wait before trying again.
+	RetryAfter int    `json:"retryAfter"`
+	Message    string `json:"message,omitempty"`
+}
+
+// maintenance is the current state shared by HTTP and socket handlers.
+var maintenance = struct {
+	sync.RWMutex
+	state       Maintenance
+	subscribers []func(Maintenance)
+}{}
+
+// SetMaintenance enables or disables maintenance mode and tells subscribers,
+// such as the socket package, so connected clients can be notified.
+func SetMaintenance(m Maintenance) {
+	if m.RetryAfter <= 0 {
+		m.RetryAfter = defaultRetryAfter
+	}
+	if m.Message == "" {
+		m.Message = "Down for maintenance"
+	}
+	maintenance.Lock()
+	maintenance.state = m
+	subscribers := maintenance.subscribers
+	maintenance.Unlock()
+
+	if m.Enabled {
+		log.Printf("Entered maintenance mode: %s", m.Message)
+	} else {
+		log.Print("Left maintenance mode")
+	}
+	for _, fn := range subscribers {
+		fn(m)
+	}
+}
+
+// CurrentMaintenance returns the maintenance state.
+func CurrentMaintenance() Maintenance {
+	maintenance.RLock()
+	defer maintenance.RUnlock()
+
+	return maintenance.state
+}
+
+// OnMaintenance calls fn whenever maintenance mode is set.
+func OnMaintenance(fn func(Maintenance)) {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+
+	maintenance.subscribers = append(maintenance.subscribers, fn)
+}
+
+// Unavailable indicates whether the site is down for maintenance for the
+// request. Clients with an address in Config.MaintenanceAllow can still use
+// it.
+func Unavailable(c Config, r *http.Request) (Maintenance, bool) {
+	m := CurrentMaintenance()
+	if !m.Enabled {
+		return m, false
+	}
+	ip := clientIP(r)
+	return m, ip == nil || !listed(c.MaintenanceAllow, ip)
+}
+
+// HandleMaintenance responds to administrative requests for the maintenance
+// state. A POST with "enabled", "retryAfter" and "message" form values
+// changes it.
+func HandleMaintenance(c Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowAdmin(c, r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+			if err != nil {
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+			retry, _ := strconv.Atoi(r.FormValue("retryAfter"))
+			SetMaintenance(Maintenance{
+				Enabled:    enabled,
+				RetryAfter: retry,
+				Message:    r.FormValue("message"),
+			})
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(CurrentMaintenance())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(content.Type, mime.JSON)
+		w.Write(data)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package coreweb
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var signalOnce sync.Once
+
+// maintenanceSignal toggles maintenance mode whenever the process receives
+// SIGUSR1, as from
+//
+//	kill -USR1 <pid>
+func maintenanceSignal() {
+	signalOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGUSR1)
+
+		go func() {
+			for range ch {
+				m := CurrentMaintenance()
+				m.Enabled = !m.Enabled
+				SetMaintenance(m)
+			}
+		}()
+	})
+}
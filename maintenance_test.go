@@ -0,0 +1,36 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenance(t *testing.T) {
+	c := Config{MaintenanceAllow: []string{"10.0.0.0/8"}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.168.1.5:4000"
+
+	var notified []Maintenance
+	OnMaintenance(func(m Maintenance) { notified = append(notified, m) })
+
+	_, unavailable := Unavailable(c, r)
+	assert.False(t, unavailable)
+
+	SetMaintenance(Maintenance{Enabled: true})
+	defer SetMaintenance(Maintenance{})
+
+	down, unavailable := Unavailable(c, r)
+	assert.True(t, unavailable)
+	assert.Equal(t, defaultRetryAfter, down.RetryAfter)
+	assert.NotEmpty(t, down.Message)
+
+	r.RemoteAddr = "10.1.2.3:4000"
+	_, unavailable = Unavailable(c, r)
+	assert.False(t, unavailable)
+
+	assert.Len(t, notified, 1)
+	assert.True(t, notified[0].Enabled)
+}
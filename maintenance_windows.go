@@ -0,0 +1,5 @@
+package coreweb
+
+// maintenanceSignal does nothing on Windows which has no user signals.
+// Maintenance mode can still be toggled with HandleMaintenance.
+func maintenanceSignal() {}
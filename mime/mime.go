@@ -1,27 +1,185 @@
 // Package mimetype enumerates MIME types.
 package mime
 
-import "strings"
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
 
 const (
+	AVIF         = "image/avif"
+	CSPReport    = "application/csp-report"
+	CSV          = "text/csv; charset=utf-8"
 	GIF          = "image/gif"
 	HTML         = "text/html; charset=utf-8"
 	Icon         = "image/x-icon"
-	JavaScript   = "text/javascript"
+	JavaScript   = "text/javascript; charset=utf-8"
 	JPEG         = "image/jpeg"
 	JSON         = "application/json"
+	Manifest     = "application/manifest+json"
+	MP4          = "video/mp4"
+	PDF          = "application/pdf"
 	PNG          = "image/png"
+	SourceMap    = "application/json"
 	SVG          = "image/svg+xml"
 	Text         = "text/plain; charset=utf-8"
+	TrueType     = "font/ttf"
 	XML          = "text/xml"
 	Raw          = "application/octet-stream"
+	Reports      = "application/reports+json"
 	StyleSheet   = "text/css"
-	OpenType     = "font/opentype"
-	WebOpenFont  = "application/woff"
-	WebOpenFont2 = "application/woff2"
+	OpenType     = "font/otf"
+	WebAssembly  = "application/wasm"
+	WebM         = "video/webm"
+	WebP         = "image/webp"
+	WebOpenFont  = "font/woff"
+	WebOpenFont2 = "font/woff2"
 	Compressed   = "application/x-compressed"
+	GZip         = "application/gzip"
+	ZIP          = "application/zip"
+)
+
+// entry is the MIME type registered for a file extension and whether content
+// of that type benefits from compression.
+type entry struct {
+	mimeType     string
+	compressible bool
+}
+
+var (
+	lock sync.RWMutex
+
+	// types are keyed by lower case file extension without a period.
+	types = map[string]entry{
+		// text and documents
+		"appcache": {"text/cache-manifest", true},
+		"atom":     {"application/atom+xml", true},
+		"css":      {StyleSheet, true},
+		"csv":      {CSV, true},
+		"htm":      {HTML, true},
+		"html":     {HTML, true},
+		"ics":      {"text/calendar", true},
+		"markdown": {"text/markdown; charset=utf-8", true},
+		"md":       {"text/markdown; charset=utf-8", true},
+		"rss":      {"application/rss+xml", true},
+		"rtf":      {"application/rtf", true},
+		"srt":      {"application/x-subrip", true},
+		"toml":     {"application/toml", true},
+		"tsv":      {"text/tab-separated-values", true},
+		"txt":      {Text, true},
+		"vtt":      {"text/vtt", true},
+		"xhtml":    {"application/xhtml+xml", true},
+		"xml":      {XML, true},
+		"xsl":      {"application/xslt+xml", true},
+		"yaml":     {"application/yaml", true},
+		"yml":      {"application/yaml", true},
+
+		// scripts and data
+		"cjs":         {JavaScript, true},
+		"geojson":     {"application/geo+json", true},
+		"js":          {JavaScript, true},
+		"json":        {JSON, true},
+		"jsonld":      {"application/ld+json", true},
+		"map":         {SourceMap, true},
+		"mjs":         {JavaScript, true},
+		"wasm":        {WebAssembly, true},
+		"webmanifest": {Manifest, true},
+
+		// images
+		"apng": {"image/apng", false},
+		"avif": {AVIF, false},
+		"bmp":  {"image/bmp", true},
+		"cur":  {Icon, true},
+		"gif":  {GIF, false},
+		"heic": {"image/heic", false},
+		"heif": {"image/heif", false},
+		"ico":  {Icon, true},
+		"jfif": {JPEG, false},
+		"jpeg": {JPEG, false},
+		"jpg":  {JPEG, false},
+		"jxl":  {"image/jxl", false},
+		"png":  {PNG, false},
+		"svg":  {SVG, true},
+		"tif":  {"image/tiff", false},
+		"tiff": {"image/tiff", false},
+		"webp": {WebP, false},
+
+		// audio and video
+		"3gp":  {"video/3gpp", false},
+		"aac":  {"audio/aac", false},
+		"avi":  {"video/x-msvideo", false},
+		"flac": {"audio/flac", false},
+		"m4a":  {"audio/mp4", false},
+		"m4v":  {MP4, false},
+		"mid":  {"audio/midi", true},
+		"midi": {"audio/midi", true},
+		"mov":  {"video/quicktime", false},
+		"mp3":  {"audio/mpeg", false},
+		"mp4":  {MP4, false},
+		"mpeg": {"video/mpeg", false},
+		"mpg":  {"video/mpeg", false},
+		"oga":  {"audio/ogg", false},
+		"ogg":  {"audio/ogg", false},
+		"ogv":  {"video/ogg", false},
+		"opus": {"audio/ogg", false},
+		"wav":  {"audio/wav", false},
+		"weba": {"audio/webm", false},
+		"webm": {WebM, false},
+
+		// fonts
+		"eot":   {"application/vnd.ms-fontobject", true},
+		"otf":   {OpenType, true},
+		"ttc":   {"font/collection", true},
+		"ttf":   {TrueType, true},
+		"woff":  {WebOpenFont, false},
+		"woff2": {WebOpenFont2, false},
+
+		// archives and office documents
+		"7z":   {"application/x-7z-compressed", false},
+		"bin":  {Raw, false},
+		"bz2":  {"application/x-bzip2", false},
+		"doc":  {"application/msword", true},
+		"docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", false},
+		"epub": {"application/epub+zip", false},
+		"gz":   {GZip, false},
+		"jar":  {"application/java-archive", false},
+		"odp":  {"application/vnd.oasis.opendocument.presentation", false},
+		"ods":  {"application/vnd.oasis.opendocument.spreadsheet", false},
+		"odt":  {"application/vnd.oasis.opendocument.text", false},
+		"pdf":  {PDF, false},
+		"ppt":  {"application/vnd.ms-powerpoint", true},
+		"pptx": {"application/vnd.openxmlformats-officedocument.presentationml.presentation", false},
+		"rar":  {"application/vnd.rar", false},
+		"tar":  {"application/x-tar", true},
+		"xls":  {"application/vnd.ms-excel", true},
+		"xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", false},
+		"zip":  {ZIP, false},
+		"zst":  {"application/zstd", false},
+	}
+
+	// compressible indicates, by MIME type without parameters, whether
+	// content benefits from compression.
+	compressible = make(map[string]bool)
 )
 
+func init() {
+	for _, e := range types {
+		compressible[Base(e.mimeType)] = e.compressible
+	}
+}
+
+// Register associates a file extension, with or without a leading period,
+// with a MIME type, replacing any built-in type for that extension.
+func Register(ext, mimeType string, canCompress bool) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	types[ext] = entry{mimeType, canCompress}
+	compressible[Base(mimeType)] = canCompress
+}
+
 // Infer MIME type from file extension. Ignore added GZip extension if present.
 func Infer(fileName string) string {
 	parts := strings.Split(strings.ToLower(fileName), ".")
@@ -31,46 +189,35 @@ func Infer(fileName string) string {
 		ext = parts[len(parts)-2]
 	}
 
-	switch strings.ToLower(ext) {
-	case "css":
-		return StyleSheet
-	case "gif":
-		return GIF
-	case "htm":
-		fallthrough
-	case "html":
-		return HTML
-	case "ico":
-		return Icon
-	case "jpg":
-		fallthrough
-	case "jpeg":
-		return JPEG
-	case "js":
-		return JavaScript
-	case "json":
-		return JSON
-	case "otf":
-		return OpenType
-	case "svg":
-		return SVG
-	case "txt":
-		return Text
-	case "png":
-		return PNG
-	case "wof":
-		fallthrough
-	case "woff":
-		return WebOpenFont
-	case "woff2":
-		return WebOpenFont2
-	case "xml":
-		return XML
-	case "gz":
-		fallthrough
-	case "zip":
-		return Compressed
-	default:
-		return Raw
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if e, ok := types[ext]; ok && len(parts) > 1 {
+		return e.mimeType
+	}
+	return Raw
+}
+
+// Sniff infers MIME type from the first bytes of content for files that have
+// no extension.
+func Sniff(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// Compressible indicates whether content of the MIME type benefits from
+// compression. Parameters such as charset are ignored.
+func Compressible(mimeType string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return compressible[Base(mimeType)]
+}
+
+// Base removes parameters, such as charset, from a MIME type and lower cases
+// it so it can be compared.
+func Base(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
 	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
 }
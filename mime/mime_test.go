@@ -17,3 +17,66 @@ func TestInfer(t *testing.T) {
 
 	assert.Equal(t, mime.SVG, mime.Infer("/img/logo.svg"))
 }
+
+func TestInferModern(t *testing.T) {
+	assert.Equal(t, mime.WebAssembly, mime.Infer("app.wasm"))
+	assert.Equal(t, mime.JavaScript, mime.Infer("module.mjs"))
+	assert.Equal(t, mime.WebP, mime.Infer("photo.webp"))
+	assert.Equal(t, mime.Manifest, mime.Infer("site.webmanifest"))
+	assert.Equal(t, mime.Raw, mime.Infer("photo.wof"))
+	assert.Equal(t, mime.Raw, mime.Infer("LICENSE"))
+}
+
+func TestRegister(t *testing.T) {
+	mime.Register(".glb", "model/gltf-binary", false)
+	mime.Register("gltf", "model/gltf+json", true)
+
+	assert.Equal(t, "model/gltf-binary", mime.Infer("scene.glb"))
+	assert.False(t, mime.Compressible("model/gltf-binary"))
+	assert.True(t, mime.Compressible(mime.Infer("scene.gltf")))
+}
+
+func TestCompressible(t *testing.T) {
+	assert.True(t, mime.Compressible(mime.JavaScript))
+	assert.True(t, mime.Compressible("text/html"))
+	assert.True(t, mime.Compressible("text/xml; charset=utf-8"))
+	assert.False(t, mime.Compressible(mime.PNG))
+	assert.False(t, mime.Compressible(mime.Raw))
+}
+
+func TestSniff(t *testing.T) {
+	assert.Equal(t, mime.HTML, mime.Sniff([]byte("<!DOCTYPE html><html></html>")))
+	assert.Equal(t, mime.Text, mime.Sniff([]byte("plain words")))
+}
+
+func TestInferTable(t *testing.T) {
+	for name, expect := range map[string]string{
+		"font.woff":     "font/woff",
+		"font.woff2":    "font/woff2",
+		"font.otf":      "font/otf",
+		"font.eot":      "application/vnd.ms-fontobject",
+		"song.mp3":      "audio/mpeg",
+		"clip.mov":      "video/quicktime",
+		"photo.jxl":     "image/jxl",
+		"scan.tiff":     "image/tiff",
+		"captions.vtt":  "text/vtt",
+		"feed.rss":      "application/rss+xml",
+		"config.yml":    "application/yaml",
+		"data.jsonld":   "application/ld+json",
+		"book.epub":     "application/epub+zip",
+		"archive.zip":   mime.ZIP,
+		"archive.tgz":   mime.Raw,
+		"archive.gz":    mime.GZip,
+		"report.docx":   "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"README.md":     "text/markdown; charset=utf-8",
+		"legacy.cjs":    mime.JavaScript,
+		"subtitles.srt": "application/x-subrip",
+	} {
+		assert.Equal(t, expect, mime.Infer(name), name)
+	}
+	assert.True(t, mime.Compressible("application/rss+xml"))
+	assert.True(t, mime.Compressible("text/markdown"))
+	assert.True(t, mime.Compressible("application/vnd.ms-fontobject"))
+	assert.False(t, mime.Compressible(mime.WebOpenFont2))
+	assert.False(t, mime.Compressible("audio/mpeg"))
+}
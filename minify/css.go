@@ -0,0 +1,61 @@
+package minify
+
+import (
+	"bytes"
+	"strings"
+)
+
+// cssPunctuation can have whitespace removed after it. Whitespace before a
+// colon is kept since it's significant in selectors like "a :hover".
+const cssPunctuation = "{};,:"
+
+// CSS removes comments and unneeded whitespace from a style sheet.
+func CSS(data []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(data))
+	space := false
+
+	// flush writes pending whitespace if the next byte needs it
+	flush := func(next byte) {
+		if space && out.Len() > 0 &&
+			!strings.ContainsRune(cssPunctuation, rune(last(&out))) &&
+			!strings.ContainsRune("{};,", rune(next)) {
+			out.WriteByte(' ')
+		}
+		space = false
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+
+		switch {
+		case c == '"' || c == '\'':
+			j := endOfString(data, i)
+			flush(c)
+			out.Write(data[i:j])
+			i = j
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			j := endOfBlockComment(data, i)
+			if keepComment(data[i:j]) {
+				flush(c)
+				out.Write(data[i:j])
+			}
+			space = true
+			i = j
+
+		case isSpace(c):
+			space = true
+			i++
+
+		default:
+			flush(c)
+			if c == '}' && last(&out) == ';' {
+				out.Truncate(out.Len() - 1)
+			}
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
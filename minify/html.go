@@ -0,0 +1,146 @@
+package minify
+
+import "bytes"
+
+// preformatted are elements whose content is kept as it is.
+var preformatted = []string{"pre", "textarea", "script", "style"}
+
+// HTML removes comments and collapses whitespace in markup. Whitespace runs
+// become a single space, or a line break if they contained one, so spacing
+// between inline elements is unchanged. Quoted attribute values and the
+// content of preformatted elements are kept as they are, as are conditional
+// comments.
+func HTML(data []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(data))
+
+	for i := 0; i < len(data); {
+		c := data[i]
+
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("<!--")):
+			j := bytes.Index(data[i+4:], []byte("-->"))
+			if j < 0 {
+				j = len(data)
+			} else {
+				j += i + 7
+			}
+			if bytes.HasPrefix(data[i:], []byte("<!--[if")) {
+				out.Write(data[i:j])
+			}
+			i = j
+
+		case c == '<':
+			j := endOfTag(data, i)
+			name := tagName(data[i:j])
+			writeTag(&out, data[i:j])
+			i = j
+
+			for _, p := range preformatted {
+				if name == p {
+					end := closingTag(data, i, p)
+					out.Write(data[i:end])
+					i = end
+					break
+				}
+			}
+
+		case isSpace(c):
+			j := i
+			for j < len(data) && isSpace(data[j]) {
+				j++
+			}
+			breaks := bytes.IndexByte(data[i:j], '\n') >= 0
+			i = j
+
+			switch prev := last(&out); {
+			case prev == ' ' && breaks:
+				// whitespace on both sides of a removed comment
+				out.Truncate(out.Len() - 1)
+				out.WriteByte('\n')
+			case isSpace(prev):
+			case breaks:
+				out.WriteByte('\n')
+			default:
+				out.WriteByte(' ')
+			}
+
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// SVG minifies SVG markup the same as HTML.
+func SVG(data []byte) []byte {
+	return HTML(data)
+}
+
+// endOfTag returns the index after the > closing a tag starting at i.
+func endOfTag(s []byte, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '"', '\'':
+			end := bytes.IndexByte(s[j+1:], s[j])
+			if end < 0 {
+				return len(s)
+			}
+			j += end + 1
+		case '>':
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// writeTag writes a tag with whitespace outside of quoted values collapsed.
+func writeTag(out *bytes.Buffer, tag []byte) {
+	var quote byte
+	space := false
+
+	for _, c := range tag {
+		switch {
+		case quote != 0:
+			out.WriteByte(c)
+			if c == quote {
+				quote = 0
+			}
+		case isSpace(c):
+			space = true
+		default:
+			if space && c != '>' {
+				out.WriteByte(' ')
+			}
+			space = false
+			if c == '"' || c == '\'' {
+				quote = c
+			}
+			out.WriteByte(c)
+		}
+	}
+}
+
+// tagName returns the lower case name of an opening tag or an empty string
+// for closing tags and declarations.
+func tagName(tag []byte) string {
+	if len(tag) < 2 || tag[1] == '/' || tag[1] == '!' || tag[1] == '?' {
+		return ""
+	}
+	end := 1
+	for end < len(tag) && !isSpace(tag[end]) && tag[end] != '>' && tag[end] != '/' {
+		end++
+	}
+	return string(bytes.ToLower(tag[1:end]))
+}
+
+// closingTag returns the index of the closing tag for an element whose
+// content begins at i.
+func closingTag(s []byte, i int, name string) int {
+	lower := bytes.ToLower(s[i:])
+	if end := bytes.Index(lower, []byte("</"+name)); end >= 0 {
+		return i + end
+	}
+	return len(s)
+}
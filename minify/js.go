@@ -0,0 +1,209 @@
+package minify
+
+import (
+	"bytes"
+	"strings"
+)
+
+// regexpFollows are the bytes after which a slash begins a regular
+// expression rather than division.
+const regexpFollows = "(,=:[!&|?{};+-*%<>~^"
+
+// regexpKeywords are words after which a slash begins a regular expression.
+var regexpKeywords = []string{
+	"return", "typeof", "case", "do", "else", "in", "instanceof", "new",
+	"delete", "void", "throw", "yield", "await",
+}
+
+// JS removes comments and unneeded whitespace from a script. Line breaks are
+// collapsed but kept so automatic semicolon insertion is unaffected.
+func JS(data []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(data))
+	space, newline := false, false
+
+	// flush writes pending whitespace if the next byte needs it
+	flush := func(next byte) {
+		prev := last(&out)
+		switch {
+		case out.Len() == 0:
+		case newline:
+			out.WriteByte('\n')
+		case space && (isWord(prev) && isWord(next) || prev == next && (next == '+' || next == '-')):
+			out.WriteByte(' ')
+		case space && next == '.' && endsWithNumber(&out):
+			// 1 .toFixed() would otherwise become the invalid 1.toFixed()
+			out.WriteByte(' ')
+		}
+		space, newline = false, false
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+
+		switch {
+		case c == '"' || c == '\'':
+			j := endOfString(data, i)
+			flush(c)
+			out.Write(data[i:j])
+			i = j
+
+		case c == '`':
+			j := endOfTemplate(data, i)
+			flush(c)
+			out.Write(data[i:j])
+			i = j
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			j := bytes.IndexByte(data[i:], '\n')
+			if j < 0 {
+				j = len(data)
+			} else {
+				j += i
+			}
+			if bytes.Contains(data[i:j], sourceMap) {
+				flush(c)
+				out.Write(bytes.TrimRight(data[i:j], "\r"))
+			}
+			space = true
+			i = j
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			j := endOfBlockComment(data, i)
+			if keepComment(data[i:j]) {
+				flush(c)
+				out.Write(data[i:j])
+			} else if bytes.IndexByte(data[i:j], '\n') >= 0 {
+				newline = true
+			}
+			space = true
+			i = j
+
+		case c == '/' && startsRegexp(&out):
+			j := endOfRegexp(data, i)
+			flush(c)
+			out.Write(data[i:j])
+			i = j
+
+		case c == '\n':
+			newline = true
+			i++
+
+		case isSpace(c):
+			space = true
+			i++
+
+		default:
+			flush(c)
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// isWord indicates whether a byte can be part of an identifier, number or
+// keyword. Multi-byte characters are assumed to be.
+func isWord(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+// endsWithNumber indicates whether the output so far ends with a word that
+// begins with a digit, so is a numeric literal.
+func endsWithNumber(out *bytes.Buffer) bool {
+	s := out.Bytes()
+	i := len(s)
+	for i > 0 && isWord(s[i-1]) {
+		i--
+	}
+	return i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
+
+// startsRegexp indicates whether a slash following the output so far begins
+// a regular expression. After an identifier, number, closing bracket or
+// postfix increment or decrement it's division.
+func startsRegexp(out *bytes.Buffer) bool {
+	s := bytes.TrimRight(out.Bytes(), " \n")
+	if len(s) == 0 {
+		return true
+	}
+	if bytes.HasSuffix(s, []byte("++")) || bytes.HasSuffix(s, []byte("--")) {
+		return false
+	}
+	if strings.IndexByte(regexpFollows, s[len(s)-1]) >= 0 {
+		return true
+	}
+	for _, k := range regexpKeywords {
+		if bytes.HasSuffix(s, []byte(k)) && (len(s) == len(k) || !isWord(s[len(s)-len(k)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// endOfRegexp returns the index after the closing slash of a regular
+// expression starting at i. Flags are handled as ordinary words.
+func endOfRegexp(s []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				return j + 1
+			}
+		case '\n':
+			return j
+		}
+	}
+	return len(s)
+}
+
+// endOfTemplate returns the index after the closing backtick of a template
+// literal starting at i, including any nested expressions.
+func endOfTemplate(s []byte, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			return j + 1
+		case '$':
+			if j+1 < len(s) && s[j+1] == '{' {
+				j = endOfExpression(s, j+2) - 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// endOfExpression returns the index after the brace closing a template
+// expression that begins at i.
+func endOfExpression(s []byte, i int) int {
+	depth := 1
+	for j := i; j < len(s); {
+		switch s[j] {
+		case '"', '\'':
+			j = endOfString(s, j)
+			continue
+		case '`':
+			j = endOfTemplate(s, j)
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+		j++
+	}
+	return len(s)
+}
@@ -0,0 +1,57 @@
+// Package minify removes comments and whitespace that browsers don't need
+// from HTML, CSS, JavaScript and SVG. The transforms are conservative: string
+// and template literals, regular expressions and preformatted elements are
+// left as they are, line breaks that JavaScript may rely on are kept and
+// license (/*!) and source map comments are preserved.
+package minify
+
+import "bytes"
+
+// sourceMap marks comments linking content to its source map.
+var sourceMap = []byte("sourceMappingURL")
+
+// isSpace indicates whether a byte is whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// keepComment indicates whether a comment is a license or source map link.
+func keepComment(comment []byte) bool {
+	return bytes.HasPrefix(comment, []byte("/*!")) || bytes.Contains(comment, sourceMap)
+}
+
+// endOfString returns the index after the closing quote of a string starting
+// at i, or the end of the data if it isn't closed.
+func endOfString(s []byte, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			if quote != '`' {
+				// unterminated
+				return j
+			}
+		}
+	}
+	return len(s)
+}
+
+// endOfBlockComment returns the index after a /* comment starting at i.
+func endOfBlockComment(s []byte, i int) int {
+	if end := bytes.Index(s[i+2:], []byte("*/")); end >= 0 {
+		return i + 2 + end + 2
+	}
+	return len(s)
+}
+
+// last returns the final byte written or zero if none has been.
+func last(out *bytes.Buffer) byte {
+	if out.Len() == 0 {
+		return 0
+	}
+	return out.Bytes()[out.Len()-1]
+}
@@ -0,0 +1,75 @@
+package minify_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/minify"
+)
+
+func TestCSS(t *testing.T) {
+	css := `/* layout */
+body {
+   margin : 0;
+   font-family: "Open  Sans", sans-serif;
+}
+a :hover , p > b { color: red; }
+/*! license */
+/*# sourceMappingURL=site.css.map */`
+
+	assert.Equal(t,
+		`body{margin :0;font-family:"Open  Sans",sans-serif}a :hover,p > b{color:red}/*! license */ /*# sourceMappingURL=site.css.map */`,
+		string(minify.CSS([]byte(css))))
+}
+
+func TestJS(t *testing.T) {
+	js := `// greet the user
+function greet (name) {
+   /* build message */
+   const message = "Hello,  " + name;
+   return message.replace(/\s+/g, ' ') + ` + "`${ name }  !`" + `;
+}
+let a = b / c, d = e - -f;
+//# sourceMappingURL=app.js.map`
+
+	assert.Equal(t,
+		"function greet(name){\nconst message=\"Hello,  \"+name;\nreturn message.replace(/\\s+/g,' ')+`${ name }  !`;\n}\nlet a=b/c,d=e- -f;\n//# sourceMappingURL=app.js.map",
+		string(minify.JS([]byte(js))))
+}
+
+func TestJSAmbiguity(t *testing.T) {
+	for js, expect := range map[string]string{
+		// a number needs the space before member access
+		`x = 1 .toFixed(2)`:   `x=1 .toFixed(2)`,
+		`x = 1.5 .toFixed(2)`: `x=1.5 .toFixed(2)`,
+		`x = a .b`:            `x=a.b`,
+		// slashes after these are division rather than a regular expression
+		`x = i++ / 2; y = 3 / 4`:     `x=i++/2;y=3/4`,
+		`x = i-- / 2; s = "a  b"`:    `x=i--/2;s="a  b"`,
+		`x = (a) / 2; y = "c / d"`:   `x=(a)/2;y="c / d"`,
+		`x = a[0] / 2; s = "e  / f"`: `x=a[0]/2;s="e  / f"`,
+		`x = total / 2`:              `x=total/2`,
+		// and these begin one
+		`x = a + /b c/.source`:         `x=a+/b c/.source`,
+		`if (x) return /a  b/.test(y)`: `if(x)return/a  b/.test(y)`,
+	} {
+		assert.Equal(t, expect, string(minify.JS([]byte(js))), js)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	html := `<!DOCTYPE html>
+<html>
+   <!-- comment -->
+   <body   class="a  b"  >
+      <p>One   <b>two</b>  three</p>
+      <pre>  keep
+   this</pre>
+      <script>var   a = "x";</script>
+   </body>
+</html>`
+
+	assert.Equal(t,
+		"<!DOCTYPE html>\n<html>\n<body class=\"a  b\">\n<p>One <b>two</b> three</p>\n<pre>  keep\n   this</pre>\n<script>var   a = \"x\";</script>\n</body>\n</html>",
+		string(minify.HTML([]byte(html))))
+}
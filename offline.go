@@ -0,0 +1,216 @@
+package coreweb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+)
+
+const (
+	workerPath   = "sw.js"
+	manifestPath = "manifest.webmanifest"
+	// revisionLength is how many hexadecimal characters of a content hash
+	// identify its revision.
+	revisionLength = 16
+)
+
+type (
+	// Manifest describes how a site is installed as a web app.
+	//
+	// https://developer.mozilla.org/en-US/docs/Web/Manifest
+	Manifest struct {
+		Name            string         `json:"name"`
+		ShortName       string         `json:"short_name,omitempty"`
+		Description     string         `json:"description,omitempty"`
+		StartURL        string         `json:"start_url"`
+		Scope           string         `json:"scope,omitempty"`
+		Display         string         `json:"display,omitempty"`
+		BackgroundColor string         `json:"background_color,omitempty"`
+		ThemeColor      string         `json:"theme_color,omitempty"`
+		Icons           []ManifestIcon `json:"icons,omitempty"`
+	}
+
+	// ManifestIcon is an image representing the installed web app.
+	ManifestIcon struct {
+		Src   string `json:"src"`
+		Sizes string `json:"sizes,omitempty"`
+		Type  string `json:"type,omitempty"`
+	}
+
+	// precache is a URL the service worker caches when installed along with
+	// the revision of its content.
+	precache struct {
+		URL      string `json:"url"`
+		Revision string `json:"revision"`
+	}
+)
+
+// generateOffline creates the configured service worker and web app
+// manifest for a cache snapshot.
+func generateOffline(c Config, m *file.Map, modules routes) map[string]*file.Info {
+	files := make(map[string]*file.Info)
+	now := time.Now()
+
+	if c.Manifest != nil {
+		data, err := json.Marshal(c.Manifest)
+		if err != nil {
+			log.Printf("Unable to create web app manifest: %v", err)
+		} else {
+			files[manifestPath] = file.FromBytes(manifestPath, data, now)
+		}
+	}
+	if c.Offline {
+		data, err := renderWorker(c, precacheEntries(c, m, modules), modules)
+		if err != nil {
+			log.Printf("Unable to create service worker: %v", err)
+			return files
+		}
+		info := file.FromBytes(workerPath, data, now)
+		// browsers should always check for a new worker
+		noCache(info)
+		files[workerPath] = info
+	}
+	return files
+}
+
+// noCache marks a file and its compressed variant to be revalidated on each
+// request.
+func noCache(info *file.Info) {
+	info.Header[header.CacheControl] = "no-cache"
+	if info.Compressed != nil {
+		info.Compressed.Header[header.CacheControl] = "no-cache"
+	}
+}
+
+// precacheEntries lists every module page and every file held in memory,
+// other than source maps, in name order with the revision of its content.
+// Pages localized for other locales are left to be cached as they're used.
+// Module pages are listed at the path they're served from, without a
+// redirect.
+func precacheEntries(c Config, m *file.Map, modules routes) []precache {
+	localized := make(map[string]bool)
+	isModule := make(map[string]bool)
+	for _, module := range modules {
+		isModule[module] = true
+		for _, locale := range c.Locales {
+			localized[localeKey(locale, module)] = true
+		}
+	}
+	keys := make([]string, 0, len(m.Files))
+	for key := range m.Files {
+		if !localized[key] && path.Ext(key) != ".map" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := make([]precache, 0, len(keys))
+	for _, key := range keys {
+		data, err := m.Content(m.Files[key])
+		if err != nil || data == nil {
+			// files too large to hold in memory are too large to precache
+			continue
+		}
+		url := webSlash + key
+		if isModule[key] {
+			url = modulePath(c, key)
+		}
+		entries = append(entries, precache{URL: url, Revision: revision(data)})
+	}
+	return entries
+}
+
+// revision identifies content by its hash.
+func revision(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])[:revisionLength]
+}
+
+// renderWorker creates the service worker source. Its cache is named for
+// the revisions of all precached files so any change installs a new worker.
+func renderWorker(c Config, entries []precache, modules routes) ([]byte, error) {
+	list, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	// module names, longest first, with the path their page is cached at
+	pages := make([][2]string, len(modules))
+	for i, module := range modules {
+		pages[i] = [2]string{module, modulePath(c, module)}
+	}
+	paths, err := json.Marshal(pages)
+	if err != nil {
+		return nil, err
+	}
+	source := strings.NewReplacer(
+		versionToken, revision(list),
+		"{precache}", string(list),
+		"{modules}", string(paths),
+	).Replace(workerSource)
+
+	return []byte(source), nil
+}
+
+// workerSource precaches static files when installed and serves them when
+// offline. Navigation beneath a module falls back to the cached module page.
+// Other requests match the full URL, so image presets aren't answered with
+// the original, except that a fingerprint alone (?v=) matches the precached
+// file, which is the current revision.
+const workerSource = `// Generated from the static file cache. Do not edit.
+const version = "{version}";
+const precache = {precache};
+const modules = {modules};
+const cacheName = "coreweb-" + version;
+
+self.addEventListener("install", event => {
+   event.waitUntil(
+      caches.open(cacheName)
+         .then(cache => cache.addAll(precache.map(entry => entry.url)))
+         .then(() => self.skipWaiting())
+   );
+});
+
+self.addEventListener("activate", event => {
+   event.waitUntil(
+      caches.keys()
+         .then(keys => Promise.all(keys
+            .filter(key => key.startsWith("coreweb-") && key !== cacheName)
+            .map(key => caches.delete(key))))
+         .then(() => self.clients.claim())
+   );
+});
+
+self.addEventListener("fetch", event => {
+   const request = event.request;
+   const url = new URL(request.url);
+
+   if (request.method !== "GET" || url.origin !== location.origin) {
+      return;
+   }
+   if (request.mode === "navigate") {
+      const module = modules.find(([m]) =>
+         url.pathname === "/" + m || url.pathname.startsWith("/" + m + "/"));
+
+      if (module) {
+         event.respondWith(fetch(request).catch(() => caches.match(module[1])));
+      }
+      return;
+   }
+   const params = [...url.searchParams.keys()];
+   const fingerprinted = params.length === 1 && params[0] === "v";
+
+   event.respondWith(
+      caches.match(request)
+         .then(cached => cached || (fingerprinted ? caches.match(url.pathname) : undefined))
+         .then(cached => cached || fetch(request))
+   );
+});
+`
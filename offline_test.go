@@ -0,0 +1,76 @@
+package coreweb
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+func offlineMap(t *testing.T, script string) *file.Map {
+	m, err := file.InFS(fstest.MapFS{
+		"html/template.html": {Data: []byte("<html>{name}</html>")},
+		"js/app.js":          {Data: []byte(script)},
+		"js/app.js.map":      {Data: []byte("{}")},
+	})
+	assert.NoError(t, err)
+	m, err = build(Config{}, m, []string{"app"})
+	assert.NoError(t, err)
+	return m
+}
+
+func TestPrecacheEntries(t *testing.T) {
+	m := offlineMap(t, "var a = 1;")
+	entries := precacheEntries(Config{}, m, newRoutes([]string{"app"}))
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "/app", entries[0].URL)
+	assert.Equal(t, "/js/app.js", entries[1].URL)
+	assert.Len(t, entries[1].Revision, revisionLength)
+
+	// pages are precached where they're served rather than a redirect
+	entries = precacheEntries(Config{TrailingSlash: true}, m, newRoutes([]string{"app"}))
+	assert.Equal(t, "/app/", entries[0].URL)
+	assert.Equal(t, "/js/app.js", entries[1].URL)
+}
+
+func TestRenderWorker(t *testing.T) {
+	c := Config{TrailingSlash: true}
+	data, err := renderWorker(c, []precache{{URL: "/app/", Revision: "abc"}}, newRoutes([]string{"app", "app/admin"}))
+	assert.NoError(t, err)
+
+	source := string(data)
+	assert.Contains(t, source, `const modules = [["app/admin","/app/admin/"],["app","/app/"]];`)
+	assert.Contains(t, source, `caches.match(request)`)
+	assert.NotContains(t, source, "{modules}")
+}
+
+func TestOfflineRegenerates(t *testing.T) {
+	c := Config{Offline: true, Manifest: &Manifest{Name: "App", StartURL: "/app"}}
+	modules := newRoutes([]string{"app"})
+	o := newGenerated(generateOffline, workerPath, manifestPath)
+
+	m := offlineMap(t, "var a = 1;")
+	worker, ok := o.find(c, m, modules, workerPath)
+	assert.True(t, ok)
+	assert.Equal(t, mime.JavaScript, worker.Header[content.Type])
+	assert.Equal(t, "no-cache", worker.Header[header.CacheControl])
+	assert.Contains(t, string(worker.Content), `"/js/app.js"`)
+
+	manifest, ok := o.find(c, m, modules, manifestPath)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"name":"App","start_url":"/app"}`, string(manifest.Content))
+
+	same, _ := o.find(c, m, modules, workerPath)
+	assert.Equal(t, worker, same)
+
+	changed, _ := o.find(c, offlineMap(t, "var a = 2;"), modules, workerPath)
+	assert.NotEqual(t, string(worker.Content), string(changed.Content))
+
+	_, ok = o.find(c, m, modules, "js/app.js")
+	assert.False(t, ok)
+}
@@ -0,0 +1,41 @@
+package coreweb
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var errTraversal = errors.New("Path may not leave the static folder")
+
+// webPath converts operating system to URL path.
+func webPath(path string) string {
+	return strings.Replace(path, osSlash, webSlash, -1)
+}
+
+// cleanPath converts a decoded URL path to the key of a static file and the
+// canonical URL path for it. Dot segments and duplicate slashes are removed
+// and page paths, those without a file extension, end with a slash only if
+// trailingSlash is set. Paths with parent segments are rejected.
+func cleanPath(p string, trailingSlash bool) (key, canonical string, err error) {
+	for _, segment := range strings.Split(p, webSlash) {
+		if segment == ".." || strings.ContainsAny(segment, "\\\x00") {
+			return "", "", errTraversal
+		}
+	}
+	key = strings.Trim(path.Clean(webSlash+p), webSlash)
+	canonical = webSlash + key
+
+	if trailingSlash && key != "" && path.Ext(key) == "" {
+		canonical += webSlash
+	}
+	return key, canonical, nil
+}
+
+// redirect permanently to the canonical path, keeping the query string.
+func redirect(w http.ResponseWriter, r *http.Request, canonical string) {
+	u := url.URL{Path: canonical, RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,57 @@
+package coreweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanPath(t *testing.T) {
+	for p, expect := range map[string][2]string{
+		"/":                {"", "/"},
+		"/app.js":          {"app.js", "/app.js"},
+		"/js//app.js":      {"js/app.js", "/js/app.js"},
+		"/js/./app.js":     {"js/app.js", "/js/app.js"},
+		"/app/":            {"app", "/app"},
+		"/app/view name":   {"app/view name", "/app/view name"},
+		"//admin///users/": {"admin/users", "/admin/users"},
+	} {
+		key, canonical, err := cleanPath(p, false)
+		assert.NoError(t, err)
+		assert.Equal(t, expect[0], key, p)
+		assert.Equal(t, expect[1], canonical, p)
+	}
+
+	key, canonical, err := cleanPath("/app", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "app", key)
+	assert.Equal(t, "/app/", canonical)
+
+	_, canonical, _ = cleanPath("/js/app.js", true)
+	assert.Equal(t, "/js/app.js", canonical)
+}
+
+func TestCleanPathTraversal(t *testing.T) {
+	for _, p := range []string{"/../secret", "/js/../../secret", "/js/..", "/js\\..\\secret", "/a\x00b"} {
+		_, _, err := cleanPath(p, false)
+		assert.Equal(t, errTraversal, err, p)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/js//app%20main.js?v=2", nil)
+	w := httptest.NewRecorder()
+
+	_, canonical, err := cleanPath(r.URL.Path, false)
+	assert.NoError(t, err)
+	redirect(w, r, canonical)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/js/app%20main.js?v=2", w.Header().Get("Location"))
+}
+
+func TestWebPath(t *testing.T) {
+	assert.Equal(t, "js/app.js", webPath("js"+osSlash+"app.js"))
+}
@@ -0,0 +1,101 @@
+package coreweb
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/mime"
+)
+
+// linkRel matches the relationship attribute of a link tag.
+var linkRel = regexp.MustCompile(`\brel\s*=\s*["']([^"']+)["']`)
+
+// preloadLinks lists Link header values for the critical assets of a module
+// page: those configured for the module followed by the scripts, style
+// sheets and module preloads the page loads.
+func preloadLinks(c Config, name string, page []byte) []string {
+	links := []string{}
+	seen := map[string]bool{}
+
+	add := func(url, link string) {
+		if !seen[url] {
+			seen[url] = true
+			links = append(links, link)
+		}
+	}
+	for _, url := range c.Preload[name] {
+		add(url, preloadLink(url))
+	}
+	for _, match := range scriptOrStyle.FindAllSubmatch(page, -1) {
+		tag, url := string(match[0]), string(match[1])
+		link := ""
+
+		if strings.HasPrefix(tag, "<script") {
+			if strings.Contains(tag, `type="module"`) {
+				link = "<" + url + ">; rel=modulepreload"
+			} else {
+				link = "<" + url + ">; rel=preload; as=script"
+			}
+		} else {
+			rel := linkRel.FindStringSubmatch(tag)
+			if rel == nil {
+				continue
+			}
+			switch strings.ToLower(rel[1]) {
+			case "stylesheet":
+				link = "<" + url + ">; rel=preload; as=style"
+			case "modulepreload":
+				link = "<" + url + ">; rel=modulepreload"
+			default:
+				continue
+			}
+		}
+		// a preload is only reused if its CORS mode matches the tag
+		if strings.Contains(tag, "crossorigin") {
+			link += "; crossorigin"
+		}
+		add(url, link)
+	}
+	return links
+}
+
+// preloadLink creates a Link header value for a configured asset, inferring
+// the kind of request from its extension.
+func preloadLink(url string) string {
+	name := url
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	link := "<" + url + ">; rel=preload; as="
+	t := mime.Infer(name)
+
+	switch {
+	case t == mime.StyleSheet:
+		return link + "style"
+	case t == mime.JavaScript:
+		return link + "script"
+	case strings.HasPrefix(t, "font/"):
+		// fonts are always fetched in CORS mode
+		return link + "font; crossorigin"
+	case strings.HasPrefix(t, "image/"):
+		return link + "image"
+	}
+	return link + "fetch; crossorigin"
+}
+
+// setPreload adds the Link header to a rendered module page and its
+// compressed variant.
+func setPreload(page *file.Info, links []string) *file.Info {
+	if len(links) == 0 {
+		return page
+	}
+	value := strings.Join(links, ", ")
+	page.Header[header.Link] = value
+
+	if page.Compressed != nil {
+		page.Compressed.Header[header.Link] = value
+	}
+	return page
+}
@@ -0,0 +1,46 @@
+package coreweb
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
+)
+
+func TestPreloadLinks(t *testing.T) {
+	c := Config{Preload: map[string][]string{
+		"app": {"/fonts/main.woff2", "/css/site.css", "/img/logo.png?v=2"},
+	}}
+	page := []byte(`<head>
+		<link rel="stylesheet" href="/css/site.css">
+		<link rel="icon" href="/favicon.ico">
+		<link rel="modulepreload" href="/js/lib.js">
+		<script src="/js/app.js" integrity="sha384-abc" crossorigin="anonymous"></script>
+		<script type="module" src="/js/main.js"></script>
+	</head>`)
+
+	assert.Equal(t, []string{
+		"</fonts/main.woff2>; rel=preload; as=font; crossorigin",
+		"</css/site.css>; rel=preload; as=style",
+		"</img/logo.png?v=2>; rel=preload; as=image",
+		"</js/lib.js>; rel=modulepreload",
+		"</js/app.js>; rel=preload; as=script; crossorigin",
+		"</js/main.js>; rel=modulepreload",
+	}, preloadLinks(c, "app", page))
+
+	assert.Empty(t, preloadLinks(c, "other", []byte(`<link rel="icon" href="/favicon.ico">`)))
+}
+
+func TestPreloadHeader(t *testing.T) {
+	m, err := file.InFS(fstest.MapFS{
+		"html/template.html": {Data: []byte(`<html><head><link rel="stylesheet" href="/css/{name}.css"></head></html>`)},
+		"css/app.css":        {Data: []byte("body { margin: 0; }")},
+	})
+	assert.NoError(t, err)
+	m, err = build(Config{}, m, []string{"app"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "</css/app.css>; rel=preload; as=style", m.Files["app"].Header[header.Link])
+}
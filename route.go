@@ -0,0 +1,37 @@
+package coreweb
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// routes are module paths, longest first, that render client-side routes
+// beneath them.
+type routes []string
+
+// newRoutes creates a route table so that nested module paths, like
+// "admin/users", match before their parents.
+func newRoutes(modulePaths []string) routes {
+	r := make(routes, 0, len(modulePaths))
+	for _, p := range modulePaths {
+		r = append(r, strings.Trim(p, webSlash))
+	}
+	sort.SliceStable(r, func(i, j int) bool { return len(r[i]) > len(r[j]) })
+	return r
+}
+
+// match returns the module path that a static file key falls beneath, such
+// as "app" for "app/view-name". Keys with a file extension are assets rather
+// than client-side routes so never match.
+func (r routes) match(key string) (string, bool) {
+	if path.Ext(key) != "" {
+		return "", false
+	}
+	for _, p := range r {
+		if key == p || strings.HasPrefix(key, p+webSlash) {
+			return p, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,29 @@
+package coreweb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatch(t *testing.T) {
+	r := newRoutes([]string{"admin", "/admin/users/", "app"})
+
+	for key, expect := range map[string]string{
+		"app":               "app",
+		"app/view":          "app",
+		"admin/settings":    "admin",
+		"admin/users":       "admin/users",
+		"admin/users/12":    "admin/users",
+		"admin/users-extra": "admin",
+	} {
+		module, ok := r.match(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, expect, module, key)
+	}
+
+	for _, key := range []string{"application", "app/missing.js", "other/view"} {
+		_, ok := r.match(key)
+		assert.False(t, ok, key)
+	}
+}
@@ -4,8 +4,10 @@ package service
 import (
 	"encoding/json"
 	"log"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/toba/coreweb"
 	"github.com/toba/coreweb/socket"
 )
 
@@ -81,14 +83,20 @@ const (
 // The payload is then unmarshalled to the indicated type and used to invoke
 // the service function itself.
 func Handle(endpoints ServiceMap) socket.RequestHandler {
+	calls.add(endpoints)
+	coreweb.Inspect("services", func() interface{} { return Stats() })
+
 	return func(socketRequest *socket.Request) []byte {
 		var res *Response
+		start := time.Now()
+		called := false
 		raw := &ServiceRequest{}
 		err := proto.Unmarshal(socketRequest.Message, raw)
 
 		if err != nil {
 			res = Error(UnableToParseRequest)
 		} else if ep, exists := endpoints[raw.ServiceID]; exists {
+			called = true
 			if ep.Expect != nil {
 				value := ep.Expect
 				if err = json.Unmarshal(raw.Payload, value); err != nil {
@@ -108,6 +116,9 @@ func Handle(endpoints ServiceMap) socket.RequestHandler {
 			res = Error(InvalidService)
 		}
 
+		if called {
+			calls.record(raw.ServiceID, res.StatusID, time.Since(start))
+		}
 		res.RequestID = raw.RequestID
 
 		return res.JSON()
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// CallStats summarizes calls to a service endpoint.
+	CallStats struct {
+		Service ServiceID `json:"service"`
+		Calls   int64     `json:"calls"`
+		// Errors counts calls that didn't return an Okay status.
+		Errors  int64         `json:"errors"`
+		Total   time.Duration `json:"-"`
+		Average string        `json:"average"`
+	}
+
+	// callStats are kept for every endpoint passed to Handle.
+	callStats struct {
+		sync.Mutex
+		services map[ServiceID]*CallStats
+	}
+)
+
+var calls = &callStats{services: make(map[ServiceID]*CallStats)}
+
+// add lists endpoints so they're reported before they're called.
+func (cs *callStats) add(endpoints ServiceMap) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	for id := range endpoints {
+		if _, ok := cs.services[id]; !ok {
+			cs.services[id] = &CallStats{Service: id}
+		}
+	}
+}
+
+// record counts a completed call.
+func (cs *callStats) record(id ServiceID, status ServiceStatus, elapsed time.Duration) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	s, ok := cs.services[id]
+	if !ok {
+		s = &CallStats{Service: id}
+		cs.services[id] = s
+	}
+	s.Calls++
+	s.Total += elapsed
+	if status != Okay {
+		s.Errors++
+	}
+}
+
+// Stats returns call statistics for each endpoint in service ID order.
+func Stats() []CallStats {
+	calls.Lock()
+	defer calls.Unlock()
+
+	list := make([]CallStats, 0, len(calls.services))
+	for _, s := range calls.services {
+		stat := *s
+		if stat.Calls > 0 {
+			stat.Average = (stat.Total / time.Duration(stat.Calls)).String()
+		}
+		list = append(list, stat)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Service < list[j].Service })
+
+	return list
+}
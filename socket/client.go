@@ -3,10 +3,15 @@
 package socket
 
 import (
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
+	"sync"
 	"time"
 
+	"github.com/toba/coreweb"
 	"github.com/toba/coreweb/header"
 
 	"strings"
@@ -24,16 +29,30 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 	// Maximum message size allowed from peer.
 	maxMessageSize = 512
+	// maxReportSize is the larger size allowed for an error notice so it
+	// can carry a stack.
+	maxReportSize = 16 << 10
 )
 
 var (
+	// reportStart matches the beginning of an error notice, which must name
+	// its type first to be read up to maxReportSize.
+	reportStart = regexp.MustCompile(`^\s*\{\s*"type"\s*:\s*"error"`)
+
 	// Active clients.
 	clients    map[*Client]bool
 	request    chan *Request
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
-	upgrader   = websocket.Upgrader{
+	// inspect and disconnect ask the event loop, which owns the client
+	// list, to describe or drop clients.
+	inspect    chan chan []ClientState
+	disconnect chan *disconnection
+	// hub ensures the channels are created and the event loop started once.
+	hub      sync.Once
+	lastID   uint64
+	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 		// CheckOrigin ensures client is allowed to connect.
@@ -50,6 +69,39 @@ type Client struct {
 	// Buffered channel of outbound messages to be picked up by the writePump.
 	Send  chan []byte
 	Token *oauth2.Token
+	// address and tenant of the connecting request give context to error
+	// reports.
+	address   string
+	tenant    int64
+	id        uint64
+	connected time.Time
+	// config and respond are those of the Handle that accepted the
+	// connection.
+	config  coreweb.Config
+	respond RequestHandler
+}
+
+// ClientState describes a connected client for the admin dashboard.
+type ClientState struct {
+	ID            uint64    `json:"id"`
+	Address       string    `json:"address"`
+	Tenant        int64     `json:"tenant"`
+	Authenticated bool      `json:"authenticated"`
+	Connected     time.Time `json:"connected"`
+	// Queued is how many messages are waiting to be sent.
+	Queued int `json:"queued"`
+}
+
+// state describes the client without exposing its token.
+func (c *Client) state() ClientState {
+	return ClientState{
+		ID:            c.id,
+		Address:       c.address,
+		Tenant:        c.tenant,
+		Authenticated: c.Token != nil,
+		Connected:     c.connected,
+		Queued:        len(c.Send),
+	}
 }
 
 // readPump processes messages from the client connection.
@@ -62,7 +114,7 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadLimit(maxReportSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -70,13 +122,23 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		message, err := c.read()
+		if err == websocket.ErrReadLimit {
+			msg := websocket.FormatCloseMessage(websocket.CloseMessageTooBig, "")
+			c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			break
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
 				log.Printf("error: %v", err)
 			}
 			break
 		}
+		if report, ok := clientError(message); ok {
+			report.Address, report.Tenant = c.address, c.tenant
+			coreweb.ReportClientError(c.config, report)
+			continue
+		}
 		request <- &Request{
 			Client:  c,
 			Message: message,
@@ -84,6 +146,33 @@ func (c *Client) readPump() {
 	}
 }
 
+// read returns the next message from the client. Messages are limited to
+// maxMessageSize except error notices, which may be up to maxReportSize, so
+// only error reports need the larger buffer.
+func (c *Client) read() ([]byte, error) {
+	_, r, err := c.conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	message, err := ioutil.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil || len(message) <= maxMessageSize {
+		return message, err
+	}
+	if !reportStart.Match(message) {
+		return nil, websocket.ErrReadLimit
+	}
+	// the connection read limit stops reports beyond maxReportSize
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	message = append(message, rest...)
+	if _, ok := clientError(message); !ok {
+		return nil, websocket.ErrReadLimit
+	}
+	return message, nil
+}
+
 // writePump sends messages to connected clients.
 //
 // It executes in one goroutine per client ensuring there is only one writer
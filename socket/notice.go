@@ -0,0 +1,111 @@
+package socket
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/toba/coreweb"
+	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+type (
+	// Notice is a reserved message the server pushes to clients outside of
+	// any service request. Its string Type distinguishes it from a service
+	// response which always has a numeric status.
+	Notice struct {
+		Type string      `json:"type"`
+		Data interface{} `json:"data,omitempty"`
+	}
+
+	// Reload tells the client which static file changed and how to apply it.
+	// A style sheet can be swapped in place by updating the href of the
+	// matching link element. Anything else requires a full page reload.
+	Reload struct {
+		Path   string `json:"path"`
+		Action string `json:"action"`
+	}
+)
+
+// Reserved notice types.
+const (
+	ReloadNotice      = "reload"
+	MaintenanceNotice = "maintenance"
+	// ErrorNotice is sent by clients, rather than the server, to report
+	// an uncaught error. Its data is a coreweb.ClientError.
+	ErrorNotice = "error"
+)
+
+// Reload actions.
+const (
+	ReloadStyle = "style"
+	ReloadPage  = "page"
+)
+
+var (
+	reloadOnce      sync.Once
+	maintenanceOnce sync.Once
+)
+
+// Notify broadcasts a reserved notice to all connected clients.
+func Notify(n *Notice) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		log.Printf("unable to marshal %s notice: %v", n.Type, err)
+		return
+	}
+	Broadcast(data)
+}
+
+// liveReload subscribes to file monitor changes so connected browsers are
+// told to refresh when static content is edited.
+func liveReload() {
+	reloadOnce.Do(func() {
+		file.Subscribe(func(c *file.Change) {
+			Notify(reloadNotice(c))
+		})
+	})
+}
+
+// maintenanceNotices subscribes to maintenance mode changes so connected
+// clients can tell users the site is going down or is back.
+func maintenanceNotices() {
+	maintenanceOnce.Do(func() {
+		coreweb.OnMaintenance(func(m coreweb.Maintenance) {
+			Notify(&Notice{Type: MaintenanceNotice, Data: m})
+		})
+	})
+}
+
+// clientError parses an error notice sent by a client. False is returned
+// for other messages, which are handled as service requests.
+func clientError(message []byte) (coreweb.ClientError, bool) {
+	n := struct {
+		Type string              `json:"type"`
+		Data coreweb.ClientError `json:"data"`
+	}{}
+	if len(message) == 0 || message[0] != '{' || json.Unmarshal(message, &n) != nil {
+		return n.Data, false
+	}
+	return n.Data, n.Type == ErrorNotice && n.Data.Message != ""
+}
+
+// reloadNotice creates the notice for a changed file. Module pages rendered
+// from the template and scripts both require a full reload.
+func reloadNotice(c *file.Change) *Notice {
+	action := ReloadPage
+
+	if strings.HasPrefix(c.Info.Header[content.Type], mime.StyleSheet) {
+		action = ReloadStyle
+	}
+	return &Notice{
+		Type: ReloadNotice,
+		Data: &Reload{
+			Path:   "/" + strings.TrimPrefix(c.Key, "/"),
+			Action: action,
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package socket
+
+import (
+	"net/http"
+
+	"github.com/toba/coreweb/header"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
+)
+
+// reloadScript opens its own socket to the URL in the data-socket attribute
+// of its script element and applies reload notices: a changed style sheet
+// is swapped by updating the href of its link elements and anything else,
+// or a style sheet the page doesn't link, reloads the page. It reconnects
+// after the server restarts.
+const reloadScript = `(() => {
+  const script = document.currentScript;
+  const url = new URL((script && script.dataset.socket) || "/socket", location.href);
+  url.protocol = url.protocol === "https:" ? "wss:" : "ws:";
+
+  const swap = (path) => {
+    let found = false;
+    for (const link of document.querySelectorAll('link[rel="stylesheet"]')) {
+      const href = new URL(link.href, location.href);
+      if (href.origin === location.origin && href.pathname === path) {
+        href.searchParams.set("reload", Date.now());
+        link.href = href.toString();
+        found = true;
+      }
+    }
+    return found;
+  };
+
+  const connect = () => {
+    const socket = new WebSocket(url);
+    socket.addEventListener("message", (e) => {
+      if (typeof e.data !== "string") return;
+      let notice;
+      try {
+        notice = JSON.parse(e.data);
+      } catch (_) {
+        return;
+      }
+      if (!notice || notice.type !== "reload" || !notice.data) return;
+      if (notice.data.action === "style" && swap(notice.data.path)) return;
+      location.reload();
+    });
+    socket.addEventListener("close", () => setTimeout(connect, 1000));
+  };
+  connect();
+})();
+`
+
+// ReloadClient serves the script that applies reload notices in the browser.
+// Pages add it while debugging, naming the path the socket Handle is mounted
+// on, and it has no effect unless Config.SyncFileAccess is on.
+//
+//	<script src="/reload.js" data-socket="/socket"></script>
+func ReloadClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(content.Type, mime.JavaScript)
+	w.Header().Set(header.CacheControl, "no-cache")
+	w.Write([]byte(reloadScript))
+}
@@ -3,12 +3,18 @@
 package socket
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/toba/coreweb"
 )
 
@@ -23,10 +29,22 @@ type (
 	// RequestHandler processes a socket request and returns a response that
 	// should be sent to the client or nil if no response is expected.
 	RequestHandler func(req *Request) []byte
+
+	// disconnection asks the event loop to drop the client with an ID and
+	// reports whether it was connected.
+	disconnection struct {
+		id    uint64
+		found chan bool
+	}
 )
 
+var errNoClient = errors.New("No connected client has that ID")
+
 const prefix = "Sec-Websocket-"
 
+// maxCloseReason is the most bytes a close frame can carry after its code.
+const maxCloseReason = 123
+
 const (
 	Accept   = prefix + "Accept"
 	Key      = prefix + "Key"
@@ -41,14 +59,25 @@ const (
 // caller" according to
 //
 // https://github.com/gorilla/websocket/commit/ea4d1f681babbce9545c9c5f3d5194a789c89f5b
+//
+// When file access is synchronized for debugging, static file changes are
+// broadcast to clients as reload notices.
 func Handle(c coreweb.Config, responder RequestHandler) func(w http.ResponseWriter, r *http.Request) {
-	broadcast = make(chan []byte)
-	request = make(chan *Request)
-	register = make(chan *Client)
-	unregister = make(chan *Client)
-	clients = make(map[*Client]bool)
+	start()
 
-	go listen(responder)
+	if c.SyncFileAccess {
+		liveReload()
+	}
+	maintenanceNotices()
+
+	coreweb.Inspect("clients", func() interface{} { return Clients() })
+	coreweb.AdminAction("disconnect", func(r *http.Request) error {
+		id, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		return Disconnect(id)
+	})
 
 	// return standard HTTP handler that upgrades to socket connection
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -67,7 +96,21 @@ func Handle(c coreweb.Config, responder RequestHandler) func(w http.ResponseWrit
 			//http.Error(w, fmt.Sprintf("cannot upgrade: %v", err), http.StatusInternalServerError)
 			return
 		}
-		client := &Client{conn: conn, Send: make(chan []byte, 256)}
+		if down, unavailable := coreweb.Unavailable(c, r); unavailable {
+			// browsers only see the close reason if the connection was opened
+			refuse(conn, down.Message)
+			return
+		}
+		client := &Client{
+			conn:      conn,
+			config:    c,
+			respond:   responder,
+			Send:      make(chan []byte, 256),
+			address:   r.RemoteAddr,
+			tenant:    coreweb.Tenant(r),
+			id:        atomic.AddUint64(&lastID, 1),
+			connected: time.Now(),
+		}
 		register <- client
 
 		go client.writePump()
@@ -75,8 +118,35 @@ func Handle(c coreweb.Config, responder RequestHandler) func(w http.ResponseWrit
 	}
 }
 
+// refuse closes a new connection with a reason the client can show and a
+// code indicating it should try again later.
+func refuse(conn *websocket.Conn, reason string) {
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+	conn.Close()
+}
+
+// start creates the event channels and begins the event loop the first time
+// it's called. Every Handle shares them so broadcasts reach all clients.
+func start() {
+	hub.Do(func() {
+		broadcast = make(chan []byte)
+		request = make(chan *Request)
+		register = make(chan *Client)
+		unregister = make(chan *Client)
+		clients = make(map[*Client]bool)
+		inspect = make(chan chan []ClientState)
+		disconnect = make(chan *disconnection)
+
+		go listen()
+	})
+}
+
 // listen is an event loop that continually checks event channels.
-func listen(responder RequestHandler) {
+func listen() {
 	for {
 		select {
 		case c := <-register:
@@ -89,12 +159,31 @@ func listen(responder RequestHandler) {
 			}
 
 		case req := <-request:
-			res := responder(req)
+			res := req.Client.respond(req)
 
 			if res != nil {
 				req.Client.Send <- res
 			}
 
+		case reply := <-inspect:
+			list := make([]ClientState, 0, len(clients))
+			for c := range clients {
+				list = append(list, c.state())
+			}
+			reply <- list
+
+		case d := <-disconnect:
+			found := false
+			for c := range clients {
+				if c.id == d.id {
+					// the write pump closes the connection once Send is closed
+					delete(clients, c)
+					close(c.Send)
+					found = true
+				}
+			}
+			d.found <- found
+
 		case res := <-broadcast:
 			for c := range clients {
 				select {
@@ -111,7 +200,32 @@ func listen(responder RequestHandler) {
 // Broadcast puts a message onto the broadcast channel to be sent to all
 // connected clients.
 func Broadcast(res []byte) {
-	if broadcast != nil && res != nil {
+	if res != nil {
+		start()
 		broadcast <- res
 	}
 }
+
+// Clients describes the connected clients in the order they connected.
+func Clients() []ClientState {
+	start()
+	reply := make(chan []ClientState)
+	inspect <- reply
+	list := <-reply
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Disconnect closes the connection of the client with the ID.
+func Disconnect(id uint64) error {
+	start()
+	d := &disconnection{id: id, found: make(chan bool)}
+	disconnect <- d
+
+	if !<-d.found {
+		return errNoClient
+	}
+	log.Printf("Disconnected client %d", id)
+	return nil
+}
@@ -1,12 +1,16 @@
 package socket_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/toba/coreweb"
+	"github.com/toba/coreweb/header/content"
+	"github.com/toba/coreweb/mime"
 	"github.com/toba/coreweb/socket"
 
 	"github.com/gorilla/websocket"
@@ -39,6 +43,11 @@ func connect(t *testing.T, h socket.RequestHandler) *websocket.Conn {
 	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
 	assert.Contains(t, res.Header, socket.Accept)
 
+	// the client is registered once it has been served
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, hello))
+	_, _, err = conn.ReadMessage()
+	assert.NoError(t, err)
+
 	return conn
 }
 
@@ -63,3 +72,110 @@ func TestServiceMessage(t *testing.T) {
 	assert.Equal(t, websocket.TextMessage, messageType)
 	assert.Equal(t, world, res)
 }
+
+func TestNotify(t *testing.T) {
+	conn := connect(t, mockHandler(t))
+
+	defer conn.Close()
+
+	socket.Notify(&socket.Notice{
+		Type: socket.ReloadNotice,
+		Data: &socket.Reload{Path: "/css/app.css", Action: socket.ReloadStyle},
+	})
+
+	_, res, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"reload","data":{"path":"/css/app.css","action":"style"}}`, string(res))
+}
+
+func TestReloadClient(t *testing.T) {
+	res := httptest.NewRecorder()
+	socket.ReloadClient(res, httptest.NewRequest(http.MethodGet, "/reload.js", nil))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, mime.JavaScript, res.Header().Get(content.Type))
+
+	// the script handles the notice types and actions the server sends
+	script := res.Body.String()
+	assert.Contains(t, script, `"`+socket.ReloadNotice+`"`)
+	assert.Contains(t, script, `"`+socket.ReloadStyle+`"`)
+	assert.Contains(t, script, "location.reload()")
+}
+
+func TestErrorNotice(t *testing.T) {
+	count := func() int64 {
+		for _, g := range coreweb.ClientErrors() {
+			if g.Message == "socket error" {
+				return g.Count
+			}
+		}
+		return 0
+	}
+	before := count()
+	conn := connect(t, mockHandler(t))
+
+	defer conn.Close()
+
+	err := conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"type":"error","data":{"message":"socket error","url":"/shop"}}`))
+	assert.NoError(t, err)
+
+	// the report isn't passed to the service handler, which expects hello
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, hello))
+	_, res, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, world, res)
+
+	assert.Equal(t, before+1, count())
+}
+
+func TestMessageSize(t *testing.T) {
+	conn := connect(t, mockHandler(t))
+
+	defer conn.Close()
+
+	// an error report naming its type first may be larger than other
+	// messages
+	report, _ := json.Marshal(socket.Notice{
+		Type: socket.ErrorNotice,
+		Data: coreweb.ClientError{
+			Message: "large report",
+			Stack:   strings.Repeat("at a (/js/app.js:1:20)\n", 50),
+		},
+	})
+	assert.True(t, len(report) > 512)
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, report))
+
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, hello))
+	_, res, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, world, res)
+
+	// any other message that large closes the connection
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte(strings.Repeat("x", 513))))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig))
+}
+
+func TestDisconnect(t *testing.T) {
+	conn := connect(t, mockHandler(t))
+
+	defer conn.Close()
+
+	// clients of earlier tests may not have been unregistered yet but the
+	// newest is last
+	clients := socket.Clients()
+	assert.NotEmpty(t, clients)
+	client := clients[len(clients)-1]
+	assert.False(t, client.Authenticated)
+	assert.Equal(t, 0, client.Queued)
+
+	assert.NoError(t, socket.Disconnect(client.ID))
+	assert.Error(t, socket.Disconnect(client.ID))
+	for _, other := range socket.Clients() {
+		assert.NotEqual(t, client.ID, other.ID)
+	}
+
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err)
+}
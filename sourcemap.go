@@ -0,0 +1,264 @@
+package coreweb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/toba/coreweb/file"
+)
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+var (
+	errVLQ = errors.New("invalid source map mapping")
+
+	// sourceMapComment matches the comment linking a script to its source
+	// map.
+	sourceMapComment = regexp.MustCompile(`//[#@]\s*sourceMappingURL=(\S+)`)
+
+	// vlqValues maps base64 characters to their 6 bit values.
+	vlqValues = func() [256]int8 {
+		var v [256]int8
+		for i := range v {
+			v[i] = -1
+		}
+		for i := 0; i < len(base64Digits); i++ {
+			v[base64Digits[i]] = int8(i)
+		}
+		return v
+	}()
+
+	// sourceMaps are parsed maps for the current cache snapshot.
+	sourceMaps = struct {
+		sync.Mutex
+		m      *file.Map
+		parsed map[string]*sourceMap
+	}{}
+)
+
+type (
+	// sourceMap relates positions in generated code to the original
+	// sources.
+	//
+	// https://sourcemaps.info/spec.html
+	sourceMap struct {
+		Version    int      `json:"version"`
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+		Names      []string `json:"names"`
+		Mappings   string   `json:"mappings"`
+		// lines are the decoded mappings for each generated line, ordered
+		// by column.
+		lines [][]mapping
+	}
+
+	// mapping is one segment of a source map. Source and name are -1 if
+	// the segment doesn't have them.
+	mapping struct {
+		column, source, line, sourceColumn, name int
+	}
+)
+
+// parseSourceMap reads and decodes a version 3 source map.
+func parseSourceMap(data []byte) (*sourceMap, error) {
+	sm := &sourceMap{}
+	if err := json.Unmarshal(data, sm); err != nil {
+		return nil, err
+	}
+	if sm.Version != 3 {
+		return nil, errors.New("only version 3 source maps are supported")
+	}
+	lines, err := decodeMappings(sm.Mappings)
+	if err != nil {
+		return nil, err
+	}
+	sm.lines = lines
+	return sm, nil
+}
+
+// decodeMappings decodes the Base64 VLQ segments of each generated line.
+// Columns restart on each line while other fields are relative to the
+// previous segment that had them.
+func decodeMappings(mappings string) ([][]mapping, error) {
+	lines := [][]mapping{}
+	source, line, sourceColumn, name := 0, 0, 0, 0
+
+	for _, text := range strings.Split(mappings, ";") {
+		segments := []mapping{}
+		column := 0
+
+		for _, segment := range strings.Split(text, ",") {
+			if segment == "" {
+				continue
+			}
+			fields, err := decodeVLQ(segment)
+			if err != nil {
+				return nil, err
+			}
+			column += fields[0]
+			m := mapping{column: column, source: -1, name: -1}
+
+			switch len(fields) {
+			case 1:
+			case 4, 5:
+				source += fields[1]
+				line += fields[2]
+				sourceColumn += fields[3]
+				m.source, m.line, m.sourceColumn = source, line, sourceColumn
+
+				if len(fields) == 5 {
+					name += fields[4]
+					m.name = name
+				}
+			default:
+				return nil, errVLQ
+			}
+			segments = append(segments, m)
+		}
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].column < segments[j].column })
+		lines = append(lines, segments)
+	}
+	return lines, nil
+}
+
+// decodeVLQ decodes the signed values of a Base64 VLQ segment. Each
+// character holds five value bits and a continuation bit, least significant
+// group first, and the lowest bit of each value is its sign.
+func decodeVLQ(segment string) ([]int, error) {
+	values := []int{}
+	value, shift := 0, uint(0)
+
+	for i := 0; i < len(segment); i++ {
+		digit := vlqValues[segment[i]]
+		if digit < 0 || shift > 30 {
+			return nil, errVLQ
+		}
+		value += int(digit&0x1f) << shift
+
+		if digit&0x20 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 == 1 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, errVLQ
+	}
+	return values, nil
+}
+
+// find returns the original source, one-based line and column and name for
+// a one-based position in the generated code.
+func (sm *sourceMap) find(line, column int) (source string, origLine, origColumn int, name string, ok bool) {
+	if line < 1 || line > len(sm.lines) {
+		return
+	}
+	segments := sm.lines[line-1]
+	// the last segment starting at or before the column
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].column > column-1 }) - 1
+	if i < 0 || segments[i].source < 0 || segments[i].source >= len(sm.Sources) {
+		return
+	}
+	m := segments[i]
+	source = sm.Sources[m.source]
+	if sm.SourceRoot != "" {
+		source = strings.TrimSuffix(sm.SourceRoot, webSlash) + webSlash + source
+	}
+	if m.name >= 0 && m.name < len(sm.Names) {
+		name = sm.Names[m.name]
+	}
+	return source, m.line + 1, m.sourceColumn + 1, name, true
+}
+
+// scriptSourceMap returns the parsed source map for a static script, found
+// through its sourceMappingURL comment or else beside it with a .map
+// extension. Parsed maps, and failures, are kept until the snapshot changes
+// but only for scripts that exist so reports can't grow the cache.
+func scriptSourceMap(m *file.Map, key string) *sourceMap {
+	script, exists := m.Files[key]
+	if !exists {
+		return nil
+	}
+	sourceMaps.Lock()
+	if sourceMaps.m != m {
+		sourceMaps.m = m
+		sourceMaps.parsed = make(map[string]*sourceMap)
+	}
+	sm, ok := sourceMaps.parsed[key]
+	sourceMaps.Unlock()
+
+	if ok {
+		return sm
+	}
+	// read and parse without holding the lock so a large map doesn't delay
+	// other reports
+	sm = loadSourceMap(m, key, script)
+
+	sourceMaps.Lock()
+	defer sourceMaps.Unlock()
+
+	if sourceMaps.m == m {
+		sourceMaps.parsed[key] = sm
+	}
+	return sm
+}
+
+// loadSourceMap reads and parses the source map of a static script, or
+// returns nil if it has none or it can't be parsed.
+func loadSourceMap(m *file.Map, key string, script *file.Info) *sourceMap {
+	mapKey := key + ".map"
+	if data, err := readFile(m, script); err == nil {
+		if match := sourceMapComment.FindSubmatch(data); match != nil {
+			ref := string(match[1])
+			if strings.Contains(ref, ":") {
+				// data URIs and other hosts aren't followed
+				return nil
+			}
+			if strings.HasPrefix(ref, webSlash) {
+				mapKey = strings.TrimPrefix(ref, webSlash)
+			} else {
+				mapKey = path.Join(path.Dir(key), ref)
+			}
+		}
+	}
+	info, exists := m.Files[mapKey]
+	if !exists {
+		return nil
+	}
+	data, err := readFile(m, info)
+	if err != nil {
+		return nil
+	}
+	sm, err := parseSourceMap(data)
+	if err != nil {
+		return nil
+	}
+	return sm
+}
+
+// readFile returns file content whether it's held in memory or streamed
+// from its source.
+func readFile(m *file.Map, info *file.Info) ([]byte, error) {
+	data, err := m.Content(info)
+	if err != nil || data != nil {
+		return data, err
+	}
+	f, err := m.Open(info)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
@@ -0,0 +1,68 @@
+package coreweb
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toba/coreweb/file"
+)
+
+// mappedScript is a minified script whose throw statement maps to line 3 of
+// the boom function in src/app.ts.
+var mappedScript = fstest.MapFS{
+	"js/app.js": {Data: []byte("function a(){throw new Error(\"x\")}\n//# sourceMappingURL=app.js.map")},
+	"js/app.js.map": {Data: []byte(`{
+		"version": 3,
+		"sources": ["app.ts"],
+		"sourceRoot": "src",
+		"names": ["boom"],
+		"mappings": "AAAA,aAEIA"
+	}`)},
+}
+
+func TestDecodeVLQ(t *testing.T) {
+	values, err := decodeVLQ("AAgBD")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 16, -1}, values)
+
+	_, err = decodeVLQ("g")
+	assert.Equal(t, errVLQ, err)
+
+	_, err = decodeVLQ("A!")
+	assert.Equal(t, errVLQ, err)
+}
+
+func TestSourceMapFind(t *testing.T) {
+	m, err := file.InFS(mappedScript)
+	assert.NoError(t, err)
+
+	sm := scriptSourceMap(m, "js/app.js")
+	assert.NotNil(t, sm)
+
+	source, line, column, name, ok := sm.find(1, 20)
+	assert.True(t, ok)
+	assert.Equal(t, "src/app.ts", source)
+	assert.Equal(t, 3, line)
+	assert.Equal(t, 5, column)
+	assert.Equal(t, "boom", name)
+
+	source, line, column, name, ok = sm.find(1, 1)
+	assert.True(t, ok)
+	assert.Equal(t, "src/app.ts", source)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, 1, column)
+	assert.Empty(t, name)
+
+	_, _, _, _, ok = sm.find(2, 1)
+	assert.False(t, ok)
+
+	// scripts that don't exist aren't cached
+	assert.Nil(t, scriptSourceMap(m, "js/other.js"))
+	sourceMaps.Lock()
+	_, cached := sourceMaps.parsed["js/other.js"]
+	_, parsed := sourceMaps.parsed["js/app.js"]
+	sourceMaps.Unlock()
+	assert.False(t, cached)
+	assert.True(t, parsed)
+}
@@ -7,12 +7,15 @@ import (
 	"net/http"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
 	"github.com/toba/coreweb/auth"
 	"github.com/toba/coreweb/encoding"
 	"github.com/toba/coreweb/file"
+	"github.com/toba/coreweb/header"
 	"github.com/toba/coreweb/header/accept"
+	"github.com/toba/coreweb/header/content"
 )
 
 const (
@@ -20,16 +23,12 @@ const (
 	webSlash      = "/"
 	templatePath  = "html" + webSlash + "template.html"
 	templateToken = "{name}"
+	versionToken  = "{version}"
 )
 
-// webPath converts operating system to URL path.
-func webPath(path string) string {
-	return strings.Replace(path, osSlash, webSlash, -1)
-}
-
 // Handle responds to all HTTP requests. Endpoints are created for all files
-// discovered in the configured path or zip file. Endpoints are also created
-// for all module paths and authentication provider callbacks.
+// discovered in the configured file system, path or zip file. Endpoints are
+// also created for all module paths and authentication provider callbacks.
 //
 // After initialization, the handler does no routing or file system reads.
 // Instead, modules perform client-side routing and retrieve data through web
@@ -38,87 +37,138 @@ func webPath(path string) string {
 // 	https://cryptic.io/go-http/
 //
 func Handle(c Config, modulePaths []string, authPaths map[string]*auth.AuthProvider) func(w http.ResponseWriter, r *http.Request) {
-	cache := &file.Map{Files: make(map[string]*file.Info)}
-	var (
-		m   *file.Map
-		err error
-	)
-
-	if file.HasZipData() && c.FromFolder == "" {
-		m, err = file.InZipFile()
-	} else {
-		// read all files in folder
-		m, err = file.InFolder(c.FromFolder, true)
-	}
-	ExitIfError(err)
-	log.Printf("Caching %d static files", len(m.Files))
-	err = m.Read(true)
+	m, err := load(c, modulePaths)
 	ExitIfError(err)
 
-	for k, v := range m.Files {
-		cache.Files[webPath(k)] = v
-	}
-
-	if _, there := cache.Files[templatePath]; len(cache.Files) < 2 || !there {
-		log.Fatalf("Invalid Template (%d files) for folder \"%s\"", len(cache.Files), c.FromFolder)
-	}
-
-	// make single reference to template and remove it from cache array
-	template := cache.Files[templatePath]
-	delete(cache.Files, templatePath)
-
-	// add cache entry for template rendered for each module
-	for _, name := range modulePaths {
-		log.Printf("Adding module endpoint /%s", name)
-		cache.Files[name] = template.Replace(templateToken, name)
-	}
+	// requests read the current snapshot without locking while changes are
+	// swapped in as new snapshots
+	cache := file.NewCache(m)
+	modules := newRoutes(modulePaths)
+	worker := newGenerated(generateOffline, workerPath, manifestPath)
+	crawl := newGenerated(generateCrawl, robotsPath, sitemapPath)
+	pictures := newImages(c.ImageCacheSize)
+	maintenanceSignal()
+	static = &site{config: c, modulePaths: modulePaths, cache: cache, images: pictures}
 
 	if c.SyncFileAccess {
 		file.Monitor(cache)
 	}
+	if c.FS == nil && c.FromZip != "" {
+		// build and swap in a new cache when the archive is replaced
+		file.MonitorArchive(c.FromZip, func() {
+			reloadArchive(c, modulePaths, cache)
+		})
+	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rvr := recover(); rvr != nil {
 				fmt.Fprintf(os.Stderr, "Panic: %+v\n", rvr)
 				debug.PrintStack()
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				writeError(w, r, cache.Map(), http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 			}
 		}()
 
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			writeError(w, r, cache.Map(), http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		m := cache.Map()
+
+		if down, unavailable := Unavailable(c, r); unavailable {
+			w.Header().Set(header.RetryAfter, strconv.Itoa(down.RetryAfter))
+			writeError(w, r, m, http.StatusServiceUnavailable, down.Message)
 			return
 		}
-		path := strings.TrimPrefix(r.RequestURI, webSlash)
-		path = strings.TrimSuffix(path, webSlash)
 
-		if c.SyncFileAccess {
-			cache.RLock()
-			defer cache.RUnlock()
+		key, canonical, err := cleanPath(r.URL.Path, c.TrailingSlash)
+		if err != nil {
+			writeError(w, r, m, http.StatusBadRequest, err.Error())
+			return
+		}
+		if canonical != r.URL.Path {
+			redirect(w, r, canonical)
+			return
 		}
 
-		info, exists := cache.Files[path]
+		// request path may include view name like /<app>/<view-name>
+		locale, key := negotiateLocale(c, r, key)
+		info, exists, localized := find(c, m, modules, key, locale)
 
+		if generated, ok := worker.find(c, m, modules, key); ok {
+			info, exists, localized = generated, true, false
+		}
 		if !exists {
-			// see if request path includes view name like /<app>/<view-name>
-			path = strings.Split(path, webSlash)[0]
-			info, exists = cache.Files[path]
+			// static robots.txt and sitemap.xml take precedence
+			info, exists = crawl.find(c, m, modules, key)
 		}
 
 		if exists {
+			links, hasLinks := info.Header[header.Link]
+
+			if len(c.ImagePresets) > 0 && r.URL.RawQuery != "" && isImage(info) {
+				resized, err := pictures.transform(c, m, key, info, r.URL.Query())
+				if err != nil {
+					writeError(w, r, m, http.StatusBadRequest, err.Error())
+					return
+				}
+				if resized != nil {
+					info = resized
+				}
+			}
 			allowGZip := strings.Contains(r.Header.Get(accept.Encoding), encoding.GZip)
 
 			if allowGZip && info.Compressed != nil {
 				info = info.Compressed
 			}
 
+			body, err := m.Content(info)
+			if err != nil {
+				writeError(w, r, m, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			tag, hasTag := info.Header[header.ETag]
+			notModified := hasTag && r.Header.Get(header.IfNoneMatch) == tag
+
+			var f file.ReadSeekCloser
+			if !notModified && body == nil {
+				// stream files too large to hold in memory
+				if f, err = m.Open(info); err != nil {
+					writeError(w, r, m, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+					return
+				}
+				defer f.Close()
+			}
+			if hasLinks && !notModified && c.EarlyHints && r.ProtoAtLeast(1, 1) {
+				// let the browser fetch critical assets while the page is
+				// sent, once it's certain the page will be
+				w.Header().Set(header.Link, links)
+				w.WriteHeader(http.StatusEarlyHints)
+			}
+
 			for k, v := range info.Header {
 				w.Header().Set(k, v)
 			}
-			w.Write(info.Content)
+			if m.Version != "" {
+				w.Header().Set(header.BundleVersion, m.Version)
+			}
+			if localized {
+				w.Header().Set(content.Language, locale)
+				w.Header().Add(header.Vary, accept.Language)
+				w.Header().Add(header.Vary, header.Cookie)
+			}
+
+			if notModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if body != nil {
+				w.Write(body)
+				return
+			}
+			http.ServeContent(w, r, info.Path, info.Modified, f)
 		} else {
-			http.Error(w, r.RequestURI+" does not exist", http.StatusNotFound)
+			writeError(w, r, m, http.StatusNotFound, r.URL.Path+" does not exist")
 		}
 	}
 }